@@ -0,0 +1,174 @@
+package follow
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kei2100/follow/file"
+)
+
+// rotatedFile is the rotated file matching the rotatedFilePathPatterns
+type rotatedFile struct {
+	path string
+	info os.FileInfo
+}
+
+var (
+	compressedExtPattern = regexp.MustCompile(`\.(gz|zst)$`)
+	numericSuffixPattern = regexp.MustCompile(`\d+$`)
+	nonDigitPattern      = regexp.MustCompile(`\D`)
+)
+
+// listRotatedFiles returns the files matching the globPatterns, except the file of the followFilePath,
+// in order from the oldest according to the key.
+func listRotatedFiles(globPatterns []string, followFilePath string, key RotatedSortKey) ([]rotatedFile, error) {
+	followInfo, err := os.Stat(followFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var files []rotatedFile
+	for _, glob := range globPatterns {
+		entries, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range entries {
+			if seen[ent] {
+				continue
+			}
+			seen[ent] = true
+			info, err := os.Stat(ent)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			if info.IsDir() || (followInfo != nil && os.SameFile(info, followInfo)) {
+				continue
+			}
+			files = append(files, rotatedFile{path: ent, info: info})
+		}
+	}
+	base := filepath.Base(followFilePath)
+	sort.SliceStable(files, func(i, j int) bool {
+		return key.older(files[i], files[j], base)
+	})
+	return files, nil
+}
+
+// nextRotatedFile returns the rotated file just newer than the current file.
+// nextRotatedFile reports false if the current file is not found in files or is the newest.
+func nextRotatedFile(files []rotatedFile, current os.FileInfo) (rotatedFile, bool) {
+	for i, f := range files {
+		if !os.SameFile(f.info, current) {
+			continue
+		}
+		if i+1 < len(files) {
+			return files[i+1], true
+		}
+		return rotatedFile{}, false
+	}
+	return rotatedFile{}, false
+}
+
+// openNextFile opens the file to read after the file currently read.
+// It is the rotated file just newer than the file currently read if any, otherwise the file of the followFilePath.
+// backlog reports whether the opened file is the rotated file.
+func (r *Reader) openNextFile() (next *os.File, dec io.ReadCloser, backlog bool, err error) {
+	if len(r.opt.rotatedFilePathPatterns) > 0 {
+		if rf, ok := r.newerRotatedFile(); ok {
+			next, dec, err := openRotatedFile(rf.path)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			r.fu.log.Info("follow: read the newer rotated file before the followed file", pathAttr(rf.path), slog.String("follow_path", r.followFilePath))
+			return next, dec, true, nil
+		}
+	}
+	next, err = file.Open(r.followFilePath)
+	return next, nil, false, err
+}
+
+// newerRotatedFile returns the rotated file just newer than the file currently read
+func (r *Reader) newerRotatedFile() (rotatedFile, bool) {
+	current, err := r.fu.fileInfo()
+	if err != nil {
+		r.fu.log.Error("follow: failed to get FileStat", pathAttr(r.fu.fileName()), errAttr(err))
+		return rotatedFile{}, false
+	}
+	files, err := listRotatedFiles(r.opt.rotatedFilePathPatterns, r.followFilePath, r.opt.rotatedSortKey)
+	if err != nil {
+		r.fu.log.Error("follow: failed to list the rotated files", errAttr(err))
+		return rotatedFile{}, false
+	}
+	return nextRotatedFile(files, current)
+}
+
+// openRotatedFile opens the rotated file, and returns the decompressing reader if the file is compressed
+func openRotatedFile(path string) (*os.File, io.ReadCloser, error) {
+	f, err := file.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec, err := openDecompressor(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, dec, nil
+}
+
+// older reports whether a is older than b according to the key.
+// base is the base name of the followFilePath.
+func (key RotatedSortKey) older(a, b rotatedFile, base string) bool {
+	switch key {
+	case RotatedSortByNumericSuffix:
+		// app.log.2 is older than app.log.1
+		an, bn := numericSuffix(a.path), numericSuffix(b.path)
+		if an != bn {
+			return an > bn
+		}
+	case RotatedSortByDateExt:
+		// app.log-20240101 is older than app.log-20240102
+		ad, bd := dateExt(a.path, base), dateExt(b.path, base)
+		if ad != bd {
+			return ad < bd
+		}
+	default:
+		if !a.info.ModTime().Equal(b.info.ModTime()) {
+			return a.info.ModTime().Before(b.info.ModTime())
+		}
+	}
+	return a.path < b.path
+}
+
+func trimCompressedExt(path string) string {
+	return compressedExtPattern.ReplaceAllString(filepath.Base(path), "")
+}
+
+// numericSuffix returns the numeric suffix of the path (e.g. 2 of app.log.2.gz).
+// numericSuffix returns -1 if the path has no numeric suffix.
+func numericSuffix(path string) int64 {
+	m := numericSuffixPattern.FindString(trimCompressedExt(path))
+	if m == "" {
+		return -1
+	}
+	n, err := strconv.ParseInt(m, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// dateExt returns the digits of the date extension of the path (e.g. 20240101 of app.log-20240101.gz)
+func dateExt(path, base string) string {
+	return nonDigitPattern.ReplaceAllString(strings.TrimPrefix(trimCompressedExt(path), base), "")
+}
@@ -0,0 +1,115 @@
+package follow
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestListRotatedFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   RotatedSortKey
+		files []string
+		want  []string
+	}{
+		{
+			name:  "Numeric suffix",
+			key:   RotatedSortByNumericSuffix,
+			files: []string{"test.log.1", "test.log.10.gz", "test.log.2.gz"},
+			want:  []string{"test.log.10.gz", "test.log.2.gz", "test.log.1"},
+		},
+		{
+			name:  "Date ext",
+			key:   RotatedSortByDateExt,
+			files: []string{"test.log-20240102", "test.log-20231231.gz", "test.log-20240101.zst"},
+			want:  []string{"test.log-20231231.gz", "test.log-20240101.zst", "test.log-20240102"},
+		},
+		{
+			name:  "Mod time",
+			key:   RotatedSortByModTime,
+			files: []string{"test.log.b", "test.log.c", "test.log.a"},
+			want:  []string{"test.log.b", "test.log.c", "test.log.a"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			live, _ := td.CreateFile("test.log")
+			live.Close()
+			mtime := time.Now().Add(-time.Hour)
+			for _, name := range tt.files {
+				f, _ := td.CreateFile(name)
+				f.Close()
+				mtime = mtime.Add(time.Minute)
+				if err := os.Chtimes(f.Name(), mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, err := listRotatedFiles([]string{filepath.Join(td.Path, "test.log*")}, live.Name(), tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range files {
+				got = append(got, filepath.Base(f.path))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("files got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("files got %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestReadRotatedBacklog(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	name := "test.log"
+	// rotated twice while not following
+	gen2, gen2Stat := td.CreateFile(name + ".2")
+	gen2.WriteString("foo")
+	gen2.Close()
+
+	gen1, _ := td.CreateFile(name + ".1.gz")
+	w := gzip.NewWriter(gen1)
+	w.Write([]byte("bar"))
+	w.Close()
+	gen1.Close()
+
+	current, currentStat := td.CreateFile(name)
+	defer current.Close()
+	current.WriteString("baz")
+
+	positionFile := posfile.InMemory(gen2Stat, 1)
+	r := mustOpenReader(
+		current.Name(),
+		WithPositionFile(positionFile),
+		WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+		WithRotatedSortKey(RotatedSortByNumericSuffix),
+		WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+	)
+	defer r.Close()
+
+	wantRead(t, r, "oobarbaz", 10*time.Millisecond, time.Second)
+	wantPositionFile(t, r, currentStat, 3)
+}
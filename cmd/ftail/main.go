@@ -1,25 +1,46 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/metrics"
+	"github.com/kei2100/follow/sink"
 )
 
 var (
+	lastLines           int
+	metricsAddr         string
+	output              string
 	positionFilePath    string
 	rotatedFilePatterns string
+	since               string
+	timeLayout          string
+	timeRegexp          string
+	watchNotify         bool
 )
 
 func init() {
+	flag.IntVar(&lastLines, "n", 0, "output the last n lines before following, unless the position-file has the prior position")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "address to serve the metrics in the Prometheus text format (e.g. :9100)")
+	flag.StringVar(&output, "output", "", "output URL. file:///path?max_bytes=N&max_backups=N, tcp://host:port, unix:///path or http(s)://host/path. stdout by default. tcp and unix receivers must acknowledge each line by replying with a newline")
 	flag.StringVar(&positionFilePath, "position-file", "", "position-file path")
 	flag.StringVar(&rotatedFilePatterns, "rotated-file-patterns", "", "comma-separated rotated file glob patterns")
+	flag.StringVar(&since, "since", "", "output from the first line logged at or after the RFC 3339 time, unless the position-file has the prior position")
+	flag.StringVar(&timeRegexp, "time-regexp", "", "regexp to extract the timestamp of the line for -since. the first submatch is used if any. RFC 3339 timestamps by default")
+	flag.StringVar(&timeLayout, "time-layout", time.RFC3339, "layout of the timestamp extracted by -time-regexp")
+	flag.BoolVar(&watchNotify, "watch-notify", false, "watch the file by the OS notification (inotify on Linux) instead of polling")
 }
 
 func main() {
@@ -39,7 +60,38 @@ func main() {
 		os.Exit(1)
 	}
 
-	opts := []follow.OptionFunc{follow.WithRotatedFilePathPatterns(strings.Split(rotatedFilePatterns, ","))}
+	opts := []follow.OptionFunc{
+		follow.WithBlockingRead(true),
+		follow.WithRotatedFilePathPatterns(strings.Split(rotatedFilePatterns, ",")),
+	}
+	var collector *metrics.Collector
+	if metricsAddr != "" {
+		collector = metrics.NewCollector()
+		opts = append(opts, follow.WithEventHandler(collector.EventHandler(subject)))
+	}
+	if lastLines > 0 {
+		opts = append(opts, follow.WithStartFromLastLines(lastLines))
+	}
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -since: %+v\n", err)
+			os.Exit(1)
+		}
+		var extractor follow.TimestampExtractor
+		if timeRegexp != "" {
+			re, err := regexp.Compile(timeRegexp)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid -time-regexp: %+v\n", err)
+				os.Exit(1)
+			}
+			extractor = follow.RegexpTimestampExtractor(re, timeLayout, nil)
+		}
+		opts = append(opts, follow.WithStartFromTime(t, extractor))
+	}
+	if watchNotify {
+		opts = append(opts, follow.WithWatchMode(follow.WatchNotify))
+	}
 	if positionFilePath != "" {
 		pf, err := follow.WithPositionFilePath(positionFilePath)
 		if err != nil {
@@ -48,19 +100,62 @@ func main() {
 		opts = append(opts, pf)
 	}
 
+	if output != "" {
+		forward(subject, opts, collector)
+		return
+	}
+
 	r, err := follow.Open(subject, opts...)
 	if err != nil {
 		panic(err)
 	}
-	defer r.Close()
+	serveMetrics(collector, subject, r)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		r.Close()
+	}()
 
-	for {
-		select {
-		case <-time.After(time.Second):
-			_, err := io.Copy(os.Stdout, r)
-			if err != nil {
-				panic(err)
-			}
-		}
+	// the blocking read returns io.EOF after the reader is closed
+	if _, err := io.Copy(os.Stdout, r); err != nil {
+		panic(err)
 	}
 }
+
+// forward writes the lines to the output. the position-file is advanced after the output acknowledges the lines
+func forward(subject string, opts []follow.OptionFunc, collector *metrics.Collector) {
+	s, err := sink.Open(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -output: %+v\n", err)
+		os.Exit(1)
+	}
+	defer s.Close()
+
+	lr, err := follow.OpenLineReader(subject, append(opts, follow.WithManualCommit(true))...)
+	if err != nil {
+		panic(err)
+	}
+	defer lr.Close()
+	serveMetrics(collector, subject, lr)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err := sink.Forward(ctx, lr, sink.Retry(s)); err != nil && ctx.Err() == nil {
+		panic(err)
+	}
+}
+
+func serveMetrics(collector *metrics.Collector, subject string, r metrics.StatsReporter) {
+	if collector == nil {
+		return
+	}
+	collector.Register(subject, r)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", collector)
+	go func() {
+		if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to serve the metrics: %+v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,126 @@
+package follow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/kei2100/follow/stat"
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompressors are the supported formats of the compressed rotated files
+var decompressors = []struct {
+	magic []byte
+	open  func(r io.Reader) (io.ReadCloser, error)
+}{
+	{
+		// gzip
+		magic: []byte{0x1f, 0x8b},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		// zstd
+		magic: []byte{0x28, 0xb5, 0x2f, 0xfd},
+		open: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+	},
+}
+
+// isCompressed reports whether f is compressed in the supported formats
+func isCompressed(f *os.File) (bool, error) {
+	magic := make([]byte, 4)
+	n, err := f.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	for _, d := range decompressors {
+		if bytes.HasPrefix(magic[:n], d.magic) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// openDecompressor returns the decompressing reader of f from the head.
+// The returned reader does not move the offset of f, so that multiple readers can be opened for f.
+// openDecompressor returns nil if f is not compressed in the supported formats.
+func openDecompressor(f *os.File) (io.ReadCloser, error) {
+	magic := make([]byte, 4)
+	n, err := f.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	for _, d := range decompressors {
+		if bytes.HasPrefix(magic[:n], d.magic) {
+			return d.open(io.NewSectionReader(f, 0, math.MaxInt64))
+		}
+	}
+	return nil, nil
+}
+
+// statCompressedFile returns the FileStat of the compressed f,
+// with the Fingerprint of the decompressed content if fingerprintSize is positive
+func statCompressedFile(log *slog.Logger, f *os.File, fingerprintSize int64) (*stat.FileStat, error) {
+	st, err := stat.Stat(f)
+	if err != nil || fingerprintSize <= 0 {
+		return st, err
+	}
+	dec, err := openDecompressor(f)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDecompressor(log, f, dec)
+	fp, err := stat.ComputeFingerprintReader(dec, fingerprintSize)
+	if err != nil {
+		return nil, err
+	}
+	st.Fingerprint = fp
+	return st, nil
+}
+
+// openCompressedSameFile returns the decompressing reader of the compressed f, if f is the file represented by findStat.
+// If findStat has the Fingerprint, f is matched by the Fingerprint of the decompressed content
+// because the compression changes the inode. Otherwise f is matched by the device and the inode.
+// The Fingerprint of the empty file matches any file, so it is not used.
+// openCompressedSameFile returns nil if f does not match.
+func openCompressedSameFile(log *slog.Logger, f *os.File, st, findStat *stat.FileStat) (io.ReadCloser, error) {
+	fp := findStat.Fingerprint
+	if fp == nil || fp.Size <= 0 {
+		if !stat.SameFile(st, findStat) {
+			return nil, nil
+		}
+		return openDecompressor(f)
+	}
+	dec, err := openDecompressor(f)
+	if err != nil {
+		log.Warn("follow: failed to decompress the file", pathAttr(f.Name()), errAttr(err))
+		return nil, nil
+	}
+	got, err := stat.ComputeFingerprintReader(dec, fp.Size)
+	closeDecompressor(log, f, dec)
+	if err != nil {
+		log.Warn("follow: failed to decompress the file", pathAttr(f.Name()), errAttr(err))
+		return nil, nil
+	}
+	if *got != *fp {
+		return nil, nil
+	}
+	return openDecompressor(f)
+}
+
+func closeDecompressor(log *slog.Logger, f *os.File, dec io.ReadCloser) {
+	if err := dec.Close(); err != nil {
+		log.Error("follow: an error occurred while closing the decompressor", pathAttr(f.Name()), errAttr(err))
+	}
+}
@@ -0,0 +1,187 @@
+// Package container decodes the log files written by the container runtimes,
+// the Docker json-file format and the CRI format of Kubernetes.
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kei2100/follow"
+)
+
+// Format is the format of the container log file
+type Format int
+
+const (
+	// FormatAuto detects the format of each line
+	FormatAuto Format = iota
+	// FormatDocker is the Docker json-file format (e.g. {"log":"hello\n","stream":"stdout","time":"2024-01-01T00:00:00.000000000Z"})
+	FormatDocker
+	// FormatCRI is the CRI format (e.g. 2024-01-01T00:00:00.000000000Z stdout F hello)
+	FormatCRI
+)
+
+// maxEntryBytes is the max size of the Entry reassembled from the partial lines.
+// The partial lines exceeding maxEntryBytes are returned as the Entry without waiting for the last partial line.
+const maxEntryBytes = 1024 * 1024
+
+// Entry is the log entry of the container
+type Entry struct {
+	// Path is the path of the log file
+	Path string
+	// Stream is the stream of the container, stdout or stderr
+	Stream string
+	// Time is the time the runtime received the entry. It is the time of the first partial line if reassembled
+	Time time.Time
+	// Log is the log message, not including the trailing newline
+	Log []byte
+	// Pod is the metadata parsed from the path of the log file
+	Pod PodInfo
+	// Position is the position in the log file up to which the Entry can be committed by Reader.Commit.
+	// It is zero if the Entry is decoded by the Decoder directly
+	Position follow.Position
+}
+
+// line is the line of the container log file
+type line struct {
+	stream  string
+	time    time.Time
+	log     []byte
+	partial bool
+}
+
+// partialKey identifies the sequence of the partial lines
+type partialKey struct {
+	path   string
+	stream string
+}
+
+// Decoder decodes the lines of the container log files into the Entries.
+// Decoder reassembles the partial lines split by the runtime for each path and stream.
+type Decoder struct {
+	format   Format
+	partials map[partialKey]*Entry
+	pods     map[string]PodInfo
+}
+
+// NewDecoder creates the Decoder of the format
+func NewDecoder(format Format) *Decoder {
+	return &Decoder{
+		format:   format,
+		partials: make(map[partialKey]*Entry),
+		pods:     make(map[string]PodInfo),
+	}
+}
+
+// Decode decodes the line of the path, not including the trailing newline.
+// Decode reports false if the line is the partial line and the Entry is not completed yet.
+func (d *Decoder) Decode(path string, b []byte) (Entry, bool, error) {
+	l, err := d.decodeLine(b)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	key := partialKey{path: path, stream: l.stream}
+	ent, ok := d.partials[key]
+	if !ok {
+		ent = &Entry{Path: path, Stream: l.stream, Time: l.time, Pod: d.pod(path)}
+	}
+	ent.Log = append(ent.Log, l.log...)
+	if l.partial && len(ent.Log) < maxEntryBytes {
+		d.partials[key] = ent
+		return Entry{}, false, nil
+	}
+	delete(d.partials, key)
+	return *ent, true, nil
+}
+
+// Forget discards the partial lines and the metadata of the path, which is no longer followed
+func (d *Decoder) Forget(path string) {
+	for key := range d.partials {
+		if key.path == path {
+			delete(d.partials, key)
+		}
+	}
+	delete(d.pods, path)
+}
+
+// partial reports whether the partial lines of the path are waiting for the last partial line
+func (d *Decoder) partial(path string) bool {
+	for key := range d.partials {
+		if key.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decoder) pod(path string) PodInfo {
+	if pod, ok := d.pods[path]; ok {
+		return pod
+	}
+	pod, _ := ParsePodInfo(path)
+	d.pods[path] = pod
+	return pod
+}
+
+func (d *Decoder) decodeLine(b []byte) (line, error) {
+	format := d.format
+	if format == FormatAuto {
+		format = FormatCRI
+		if len(b) > 0 && b[0] == '{' {
+			format = FormatDocker
+		}
+	}
+	if format == FormatDocker {
+		return decodeDocker(b)
+	}
+	return decodeCRI(b)
+}
+
+// dockerLine is the line of the Docker json-file format
+type dockerLine struct {
+	Log    string    `json:"log"`
+	Stream string    `json:"stream"`
+	Time   time.Time `json:"time"`
+}
+
+// decodeDocker decodes the line of the Docker json-file format.
+// The log not terminated by the newline is the partial line.
+func decodeDocker(b []byte) (line, error) {
+	var dl dockerLine
+	if err := json.Unmarshal(b, &dl); err != nil {
+		return line{}, fmt.Errorf("container: invalid json-file line: %w", err)
+	}
+	log, partial := strings.CutSuffix(dl.Log, "\n")
+	return line{stream: dl.Stream, time: dl.Time, log: []byte(log), partial: !partial}, nil
+}
+
+// decodeCRI decodes the line of the CRI format, "<time> <stream> <tags> <log>".
+// The tags are delimited by ':', and the first tag is P for the partial line or F for the full line.
+func decodeCRI(b []byte) (line, error) {
+	fields := bytes.SplitN(b, []byte{' '}, 4)
+	if len(fields) < 3 {
+		return line{}, errors.New("container: invalid CRI line: too few fields")
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(fields[0]))
+	if err != nil {
+		return line{}, fmt.Errorf("container: invalid CRI line: %w", err)
+	}
+	tag, _, _ := bytes.Cut(fields[2], []byte{':'})
+	var partial bool
+	switch string(tag) {
+	case "P":
+		partial = true
+	case "F":
+	default:
+		return line{}, fmt.Errorf("container: invalid CRI line: unknown tag %q", fields[2])
+	}
+	l := line{stream: string(fields[1]), time: t, partial: partial}
+	if len(fields) == 4 {
+		l.log = append([]byte(nil), fields[3]...)
+	}
+	return l, nil
+}
@@ -0,0 +1,86 @@
+package container
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Second)
+
+	t.Run("Docker", func(t *testing.T) {
+		t.Parallel()
+
+		d := NewDecoder(FormatDocker)
+		wantNoEntry(t, d, "a.log", `{"log":"hello ","stream":"stdout","time":"2024-01-01T00:00:00Z"}`)
+		wantEntry(t, d, "a.log", `{"log":"error\n","stream":"stderr","time":"2024-01-01T00:00:01Z"}`, Entry{Path: "a.log", Stream: "stderr", Time: t1, Log: []byte("error")})
+		wantEntry(t, d, "a.log", `{"log":"world\n","stream":"stdout","time":"2024-01-01T00:00:01Z"}`, Entry{Path: "a.log", Stream: "stdout", Time: t0, Log: []byte("hello world")})
+		wantDecodeError(t, d, "a.log", `2024-01-01T00:00:00Z stdout F hello`)
+	})
+
+	t.Run("CRI", func(t *testing.T) {
+		t.Parallel()
+
+		d := NewDecoder(FormatCRI)
+		wantNoEntry(t, d, "a.log", `2024-01-01T00:00:00Z stdout P hello `)
+		// the partial lines of the other file are reassembled separately
+		wantNoEntry(t, d, "b.log", `2024-01-01T00:00:00Z stdout P foo`)
+		wantEntry(t, d, "a.log", `2024-01-01T00:00:01Z stderr F error`, Entry{Path: "a.log", Stream: "stderr", Time: t1, Log: []byte("error")})
+		wantEntry(t, d, "a.log", `2024-01-01T00:00:01Z stdout F:x world`, Entry{Path: "a.log", Stream: "stdout", Time: t0, Log: []byte("hello world")})
+		wantEntry(t, d, "a.log", `2024-01-01T00:00:01Z stdout F`, Entry{Path: "a.log", Stream: "stdout", Time: t1, Log: nil})
+		wantEntry(t, d, "b.log", `2024-01-01T00:00:01Z stdout F bar`, Entry{Path: "b.log", Stream: "stdout", Time: t0, Log: []byte("foobar")})
+		wantDecodeError(t, d, "a.log", `2024-01-01T00:00:01Z stdout X foo`)
+		wantDecodeError(t, d, "a.log", `foo bar`)
+
+		d.Forget("a.log")
+		wantNoEntry(t, d, "a.log", `2024-01-01T00:00:00Z stdout P foo`)
+		d.Forget("a.log")
+		wantEntry(t, d, "a.log", `2024-01-01T00:00:01Z stdout F bar`, Entry{Path: "a.log", Stream: "stdout", Time: t1, Log: []byte("bar")})
+	})
+
+	t.Run("Auto", func(t *testing.T) {
+		t.Parallel()
+
+		d := NewDecoder(FormatAuto)
+		wantEntry(t, d, "a.log", `{"log":"foo\n","stream":"stdout","time":"2024-01-01T00:00:00Z"}`, Entry{Path: "a.log", Stream: "stdout", Time: t0, Log: []byte("foo")})
+		wantEntry(t, d, "b.log", `2024-01-01T00:00:00Z stdout F bar`, Entry{Path: "b.log", Stream: "stdout", Time: t0, Log: []byte("bar")})
+	})
+}
+
+func wantEntry(t *testing.T, d *Decoder, path, line string, want Entry) {
+	t.Helper()
+
+	got, ok, err := d.Decode(path, []byte(line))
+	if err != nil {
+		t.Fatalf("failed to decode %+v", err)
+	}
+	if !ok {
+		t.Fatalf("entry not completed. line %s", line)
+	}
+	if got.Path != want.Path || got.Stream != want.Stream || !got.Time.Equal(want.Time) || string(got.Log) != string(want.Log) || got.Pod != want.Pod {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func wantNoEntry(t *testing.T, d *Decoder, path, line string) {
+	t.Helper()
+
+	got, ok, err := d.Decode(path, []byte(line))
+	if err != nil {
+		t.Fatalf("failed to decode %+v", err)
+	}
+	if ok {
+		t.Errorf("unexpected entry %+v", got)
+	}
+}
+
+func wantDecodeError(t *testing.T, d *Decoder, path, line string) {
+	t.Helper()
+
+	if _, _, err := d.Decode(path, []byte(line)); err == nil {
+		t.Errorf("err got nil. line %s", line)
+	}
+}
@@ -0,0 +1,46 @@
+package container
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PodInfo is the metadata of the container parsed from the path of the log file
+type PodInfo struct {
+	Namespace   string
+	Pod         string
+	PodUID      string
+	Container   string
+	ContainerID string
+}
+
+var (
+	// containersLogPattern matches the base name of /var/log/containers/<pod>_<namespace>_<container>-<container id>.log
+	containersLogPattern = regexp.MustCompile(`^([^_]+)_([^_]+)_(.+)-([0-9a-f]{64})\.log$`)
+	// podsLogPattern matches /var/log/pods/<namespace>_<pod>_<pod uid>/<container>/<restart count>.log
+	podsLogPattern = regexp.MustCompile(`(?:^|/)([^_/]+)_([^_/]+)_([^_/]+)/([^/]+)/\d+\.log$`)
+	// dockerLogPattern matches the base name of /var/lib/docker/containers/<container id>/<container id>-json.log
+	dockerLogPattern = regexp.MustCompile(`^([0-9a-f]{64})-json\.log$`)
+)
+
+// ParsePodInfo parses the path of the log file in the layouts of the kubelet and the Docker.
+// ParsePodInfo reports false if the path does not match the layouts.
+func ParsePodInfo(path string) (PodInfo, bool) {
+	base := filepath.Base(path)
+	if m := containersLogPattern.FindStringSubmatch(base); m != nil {
+		return PodInfo{Pod: m[1], Namespace: m[2], Container: m[3], ContainerID: m[4]}, true
+	}
+	if m := podsLogPattern.FindStringSubmatch(filepath.ToSlash(path)); m != nil {
+		return PodInfo{Namespace: m[1], Pod: m[2], PodUID: m[3], Container: m[4]}, true
+	}
+	if m := dockerLogPattern.FindStringSubmatch(base); m != nil {
+		return PodInfo{ContainerID: m[1]}, true
+	}
+	return PodInfo{}, false
+}
+
+// String returns the namespace/pod/container
+func (p PodInfo) String() string {
+	return strings.Join([]string{p.Namespace, p.Pod, p.Container}, "/")
+}
@@ -0,0 +1,40 @@
+package container
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePodInfo(t *testing.T) {
+	t.Parallel()
+
+	id := strings.Repeat("0123456789abcdef", 4)
+	tests := []struct {
+		path   string
+		want   PodInfo
+		wantOK bool
+	}{
+		{
+			path:   "/var/log/containers/web-7d4b9c8f6-abcde_default_nginx-" + id + ".log",
+			want:   PodInfo{Namespace: "default", Pod: "web-7d4b9c8f6-abcde", Container: "nginx", ContainerID: id},
+			wantOK: true,
+		},
+		{
+			path:   "/var/log/pods/kube-system_coredns-5d78c9869d-x7k2p_3f1c2a7e-1111-2222-3333-444455556666/coredns/0.log",
+			want:   PodInfo{Namespace: "kube-system", Pod: "coredns-5d78c9869d-x7k2p", PodUID: "3f1c2a7e-1111-2222-3333-444455556666", Container: "coredns"},
+			wantOK: true,
+		},
+		{
+			path:   "/var/lib/docker/containers/" + id + "/" + id + "-json.log",
+			want:   PodInfo{ContainerID: id},
+			wantOK: true,
+		},
+		{path: "/var/log/syslog"},
+	}
+	for _, tt := range tests {
+		got, ok := ParsePodInfo(tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s got %+v %v, want %+v %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
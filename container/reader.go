@@ -0,0 +1,170 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/logger"
+)
+
+// Reader reads the Entries from the container log files matching the glob patterns.
+// The log files of the new containers are followed automatically by the follow.MultiReader.
+//
+// The follow.MultiReader is opened with follow.WithManualCommit, and the positionFiles advance only by Commit.
+// The partial lines read but not returned as the Entry yet are read again after the restart,
+// so the Entries not committed are read again, but never lost.
+type Reader struct {
+	mr  *follow.MultiReader
+	dec *Decoder
+	// bufs are the bytes not terminated by the newline for each path
+	bufs map[string][]byte
+	// starts are the positions of the heads of bufs
+	starts map[string]follow.Position
+	// partialStarts are the positions of the first partial lines not reassembled into the Entries yet
+	partialStarts map[string]follow.Position
+	// generation is the follow.MultiReader.PathsGeneration when the state of the unfollowed paths was discarded
+	generation uint64
+	entries    []Entry
+	chunk      []byte
+	log        *slog.Logger
+	mu         sync.Mutex
+}
+
+// OpenGlob opens the container log files matching the globPatterns (e.g. /var/log/containers/*.log) in the format.
+// opts are passed to follow.OpenGlob.
+func OpenGlob(globPatterns []string, format Format, opts ...follow.OptionFunc) (*Reader, error) {
+	opts = append(append([]follow.OptionFunc{}, opts...), follow.WithManualCommit(true))
+	mr, err := follow.OpenGlob(globPatterns, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		mr:            mr,
+		dec:           NewDecoder(format),
+		bufs:          make(map[string][]byte),
+		starts:        make(map[string]follow.Position),
+		partialStarts: make(map[string]follow.Position),
+		chunk:         make([]byte, 64*1024),
+		log:           logger.Default(),
+	}, nil
+}
+
+// ReadEntry reads the Entry from one of the followed files.
+// The lines failed to decode are logged and skipped.
+// ReadEntry blocks until the Entry is available or ctx is done.
+// ReadEntry returns ctx.Err() if ctx is done, and io.EOF if the Reader is closed while waiting.
+func (r *Reader) ReadEntry(ctx context.Context) (Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for len(r.entries) == 0 {
+		c, err := r.mr.ReadChunk(ctx, r.chunk)
+		if err != nil {
+			return Entry{}, err
+		}
+		if gen := r.mr.PathsGeneration(); gen != r.generation {
+			r.generation = gen
+			r.forgetUnfollowed()
+		}
+		r.decode(c)
+	}
+	ent := r.entries[0]
+	r.entries = r.entries[1:]
+	return ent, nil
+}
+
+// Commit commits the Position of the Entry returned by ReadEntry to the positionFile of its path,
+// so that the Entry and the Entries before it in the path are not read again after the restart.
+// The Entries of each path must be committed in the order returned by ReadEntry.
+func (r *Reader) Commit(ent Entry) error {
+	return r.mr.CommitUpTo(ent.Path, ent.Position)
+}
+
+// decode decodes the complete lines in the bytes of the chunk
+func (r *Reader) decode(c follow.Chunk) {
+	path := c.Path
+	// the end of the line is computed from the position just after the chunk
+	endOf := func(remaining []byte) follow.Position {
+		return follow.Position{FileStat: c.Position.FileStat, Offset: c.Position.Offset - int64(len(remaining))}
+	}
+	start, ok := r.starts[path]
+	if !ok {
+		start = endOf(c.Data)
+	}
+	buf := append(r.bufs[path], c.Data...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+		b := bytes.TrimSuffix(buf[:i], []byte{'\r'})
+		buf = buf[i+1:]
+		end := endOf(buf)
+		ent, ok, err := r.dec.Decode(path, b)
+		if err == nil && !ok {
+			if _, pending := r.partialStarts[path]; !pending {
+				r.partialStarts[path] = start
+			}
+		}
+		if !r.dec.partial(path) {
+			delete(r.partialStarts, path)
+		}
+		start = end
+		if err != nil {
+			r.log.Warn("container: failed to decode the line", slog.String("path", path), slog.Any("error", err))
+			continue
+		}
+		if ok {
+			// the Entry cannot be committed past the partial lines of the other stream
+			ent.Position = end
+			if pos, pending := r.partialStarts[path]; pending {
+				ent.Position = pos
+			}
+			r.entries = append(r.entries, ent)
+		}
+	}
+	if len(buf) == 0 {
+		delete(r.bufs, path)
+		delete(r.starts, path)
+		return
+	}
+	r.bufs[path] = append([]byte(nil), buf...)
+	r.starts[path] = start
+}
+
+// forgetUnfollowed discards the state of the paths no longer followed
+func (r *Reader) forgetUnfollowed() {
+	followed := make(map[string]bool)
+	for _, path := range r.mr.Paths() {
+		followed[path] = true
+	}
+	for path := range r.dec.pods {
+		if !followed[path] {
+			r.dec.Forget(path)
+		}
+	}
+	for path := range r.bufs {
+		if !followed[path] {
+			delete(r.bufs, path)
+			delete(r.starts, path)
+		}
+	}
+	for path := range r.partialStarts {
+		if !followed[path] {
+			delete(r.partialStarts, path)
+		}
+	}
+}
+
+// Paths returns the paths currently followed
+func (r *Reader) Paths() []string {
+	return r.mr.Paths()
+}
+
+// Close closes the Reader and all the followed files
+func (r *Reader) Close() error {
+	return r.mr.Close()
+}
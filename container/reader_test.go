@@ -0,0 +1,134 @@
+package container
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	id := strings.Repeat("0123456789abcdef", 4)
+	name := "web_default_nginx-" + id + ".log"
+	f, _ := td.CreateFile(name)
+	defer f.Close()
+
+	r, err := OpenGlob(
+		[]string{filepath.Join(td.Path, "*.log")},
+		FormatAuto,
+		follow.WithGlobInterval(10*time.Millisecond),
+		follow.WithReadPollInterval(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	f.WriteString("2024-01-01T00:00:00Z stdout P hel")
+	f.WriteString("lo\n2024-01-01T00:00:01Z stdout F  world\n")
+	wantReadEntry(t, r, Entry{
+		Path:   f.Name(),
+		Stream: "stdout",
+		Time:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Log:    []byte("hello world"),
+		Pod:    PodInfo{Namespace: "default", Pod: "web", Container: "nginx", ContainerID: id},
+	})
+
+	// the log file of the new container is followed
+	docker, _ := td.CreateFile("new.log")
+	defer docker.Close()
+	docker.WriteString(`{"log":"foo\n","stream":"stderr","time":"2024-01-01T00:00:02Z"}` + "\n")
+	wantReadEntry(t, r, Entry{
+		Path:   docker.Name(),
+		Stream: "stderr",
+		Time:   time.Date(2024, 1, 1, 0, 0, 2, 0, time.UTC),
+		Log:    []byte("foo"),
+	})
+}
+
+func TestReaderCommit(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, _ := td.CreateFile("test.log")
+	defer f.Close()
+
+	store, err := posfile.OpenStore(filepath.Join(td.Path, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	open := func() *Reader {
+		r, err := OpenGlob(
+			[]string{filepath.Join(td.Path, "*.log")},
+			FormatCRI,
+			follow.WithPositionFileFunc(store.PositionFile),
+			follow.WithReadFromHead(true),
+			follow.WithReadPollInterval(10*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	hel := "2024-01-01T00:00:00Z stdout P hel\n"
+	oops := "2024-01-01T00:00:01Z stderr F oops\n"
+	lo := "2024-01-01T00:00:02Z stdout F lo"
+	f.WriteString(hel + oops + lo)
+
+	r := open()
+	ent := wantReadEntry(t, r, Entry{Path: f.Name(), Stream: "stderr", Time: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC), Log: []byte("oops")})
+	// the Entry cannot be committed past the partial line of stdout
+	if g, w := ent.Position.Offset, int64(0); g != w {
+		t.Errorf("position got %v, want %v", g, w)
+	}
+	if err := r.Commit(ent); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	// the partial lines are read again after the restart
+	f.WriteString("\n")
+	r = open()
+	defer r.Close()
+	wantReadEntry(t, r, Entry{Path: f.Name(), Stream: "stderr", Time: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC), Log: []byte("oops")})
+	ent = wantReadEntry(t, r, Entry{Path: f.Name(), Stream: "stdout", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Log: []byte("hello")})
+	if g, w := ent.Position.Offset, int64(len(hel+oops+lo)+1); g != w {
+		t.Errorf("position got %v, want %v", g, w)
+	}
+	if err := r.Commit(ent); err != nil {
+		t.Fatal(err)
+	}
+	pf, _ := store.PositionFile(f.Name())
+	if g, w := pf.Offset(), ent.Position.Offset; g != w {
+		t.Errorf("committed offset got %v, want %v", g, w)
+	}
+}
+
+func wantReadEntry(t *testing.T, r *Reader, want Entry) Entry {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	got, err := r.ReadEntry(ctx)
+	if err != nil {
+		t.Fatalf("failed to read entry %+v", err)
+	}
+	if got.Path != want.Path || got.Stream != want.Stream || !got.Time.Equal(want.Time) || string(got.Log) != string(want.Log) || got.Pod != want.Pod {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	return got
+}
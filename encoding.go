@@ -0,0 +1,404 @@
+package follow
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Encoding is the character encoding of the followed file
+type Encoding int
+
+const (
+	// EncodingNone passes the bytes of the file through without the conversion
+	EncodingNone Encoding = iota
+	// EncodingAuto detects the encoding by the BOM (UTF-8, UTF-16LE or UTF-16BE) at the head of the file.
+	// The file without the BOM is passed through
+	EncodingAuto
+	// EncodingShiftJIS is Shift_JIS
+	EncodingShiftJIS
+	// EncodingEUCJP is EUC-JP
+	EncodingEUCJP
+	// EncodingUTF16LE is UTF-16 little endian. The BOM at the head of the file is skipped
+	EncodingUTF16LE
+	// EncodingUTF16BE is UTF-16 big endian. The BOM at the head of the file is skipped
+	EncodingUTF16BE
+	// encodingUTF8 is UTF-8 detected by the BOM
+	encodingUTF8
+)
+
+// EncodingErrorPolicy is the policy applied when the invalid byte sequence in the encoding is read
+type EncodingErrorPolicy int
+
+const (
+	// EncodingErrorReplace replaces the invalid byte sequence with U+FFFD
+	EncodingErrorReplace EncodingErrorPolicy = iota
+	// EncodingErrorReport skips the invalid byte sequence, and Read returns *EncodingError
+	EncodingErrorReport
+)
+
+// EncodingError is returned by Read when the invalid byte sequence is read with EncodingErrorReport.
+// The next Read continues from the bytes following the invalid byte sequence.
+type EncodingError struct {
+	// Offset is the offset of the invalid byte sequence in the file
+	Offset int64
+	// Bytes is the invalid byte sequence
+	Bytes []byte
+}
+
+func (e *EncodingError) Error() string {
+	return fmt.Sprintf("follow: invalid byte sequence % x at offset %d", e.Bytes, e.Offset)
+}
+
+// encodingSpec is the spec of the Encoding
+type encodingSpec struct {
+	transformer func() transform.Transformer
+	// bom is the byte order mark skipped at the head of the file
+	bom []byte
+	// replacement is U+FFFD in the encoding, which is distinguished from the invalid byte sequence
+	replacement []byte
+}
+
+var encodingSpecs = map[Encoding]encodingSpec{
+	EncodingShiftJIS: {
+		transformer: func() transform.Transformer { return japanese.ShiftJIS.NewDecoder() },
+	},
+	EncodingEUCJP: {
+		transformer: func() transform.Transformer { return japanese.EUCJP.NewDecoder() },
+	},
+	EncodingUTF16LE: {
+		transformer: func() transform.Transformer {
+			return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+		},
+		bom:         []byte{0xff, 0xfe},
+		replacement: []byte{0xfd, 0xff},
+	},
+	EncodingUTF16BE: {
+		transformer: func() transform.Transformer {
+			return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+		},
+		bom:         []byte{0xfe, 0xff},
+		replacement: []byte{0xff, 0xfd},
+	},
+	encodingUTF8: {
+		transformer: func() transform.Transformer { return unicode.UTF8.NewDecoder() },
+		bom:         []byte{0xef, 0xbb, 0xbf},
+		replacement: []byte{0xef, 0xbf, 0xbd},
+	},
+}
+
+// bomEncodings are the Encodings detected by EncodingAuto
+var bomEncodings = []Encoding{encodingUTF8, EncodingUTF16LE, EncodingUTF16BE}
+
+const (
+	maxBOMLen          = 3
+	converterChunkSize = 4096
+	replacementChar    = "\ufffd"
+)
+
+// detectEncoding returns the encoding of the file starting with head.
+// EncodingAuto is resolved by the BOM at the head.
+func detectEncoding(encoding Encoding, head []byte) Encoding {
+	if encoding != EncodingAuto {
+		return encoding
+	}
+	for _, e := range bomEncodings {
+		if bytes.HasPrefix(head, encodingSpecs[e].bom) {
+			return e
+		}
+	}
+	return EncodingNone
+}
+
+// sourceDelimiter returns the line delimiter encoded in the encoding.
+// The delimiter of UTF-16 is found only at the boundary of the code units.
+func sourceDelimiter(encoding Encoding, delim byte) []byte {
+	switch encoding {
+	case EncodingUTF16LE:
+		return []byte{delim, 0}
+	case EncodingUTF16BE:
+		return []byte{0, delim}
+	default:
+		return []byte{delim}
+	}
+}
+
+// sourceDelimiterOf returns the line delimiter encoded in the encoding of f
+func sourceDelimiterOf(f io.ReaderAt, opt option) ([]byte, error) {
+	head := make([]byte, maxBOMLen)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return sourceDelimiter(detectEncoding(opt.encoding, head[:n]), opt.lineDelimiter), nil
+}
+
+// indexDelimiter returns the index of the first delimiter returned by sourceDelimiter in b, or -1 if not found
+func indexDelimiter(b, delim []byte) int {
+	if len(delim) == 1 {
+		return bytes.IndexByte(b, delim[0])
+	}
+	for i := 0; i+len(delim) <= len(b); i += len(delim) {
+		if bytes.Equal(b[i:i+len(delim)], delim) {
+			return i
+		}
+	}
+	return -1
+}
+
+// converter converts the bytes of the file in the source encoding into UTF-8.
+// converter converts only the complete characters, so that the offset in the file never lands inside a character.
+type converter struct {
+	encoding Encoding
+	policy   EncodingErrorPolicy
+	delim    byte
+	spec     encodingSpec
+	t        transform.Transformer
+	// sdelim is the delimiter encoded in the detected encoding
+	sdelim    []byte
+	detected  bool
+	bomLength int
+	// buf holds the bytes read from the file but not converted yet
+	buf []byte
+	// offset is the offset of buf[0] in the file
+	offset int64
+	// spans hold the converted bytes not read yet
+	spans []span
+	chunk []byte
+}
+
+// span is the converted bytes of at most one line
+type span struct {
+	out []byte
+	// srcLen is the number of the bytes of the file converted into the span
+	srcLen int
+	// err is the *EncodingError of the invalid byte sequence skipped by the span
+	err error
+}
+
+func newConverter(opt option, offset int64) *converter {
+	return &converter{
+		encoding: opt.encoding,
+		policy:   opt.encodingErrorPolicy,
+		delim:    opt.lineDelimiter,
+		offset:   offset,
+		chunk:    make([]byte, converterChunkSize),
+	}
+}
+
+// reset discards the bytes not converted, and restarts the conversion from the offset of the file
+func (c *converter) reset(offset int64) {
+	c.buf = nil
+	c.spans = nil
+	c.offset = offset
+	c.detected = false
+}
+
+// sourceDelimiter returns the line delimiter encoded in the encoding of the file
+func (c *converter) sourceDelimiter(head func() ([]byte, error)) ([]byte, error) {
+	if c.detected {
+		return c.sdelim, nil
+	}
+	h, err := head()
+	if err != nil {
+		return nil, err
+	}
+	return sourceDelimiter(detectEncoding(c.encoding, h), c.delim), nil
+}
+
+// read reads the bytes from src and converts them into p.
+// head returns the bytes at the head of the file to detect the BOM.
+// consumed is the number of the bytes of the file that have been converted and read into p.
+// The returned bytes contain at most one line, so that the end of the line corresponds to the offset in the file.
+func (c *converter) read(src io.Reader, head func() ([]byte, error), p []byte) (n, consumed int, err error) {
+	for {
+		if !c.detected {
+			if err := c.detect(head); err != nil {
+				return 0, consumed, err
+			}
+		}
+		if c.detected {
+			consumed += c.skipBOM()
+			if c.t == nil && len(c.buf) > 0 {
+				n = copy(p, c.buf)
+				c.consume(n)
+				return n, consumed + n, nil
+			}
+			if err := c.convert(); err != nil {
+				return 0, consumed, err
+			}
+			for len(c.spans) > 0 {
+				s := &c.spans[0]
+				if s.err != nil {
+					err := s.err
+					consumed += s.srcLen
+					c.spans = c.spans[1:]
+					return 0, consumed, err
+				}
+				n = copy(p, s.out)
+				s.out = s.out[n:]
+				if len(s.out) == 0 {
+					consumed += s.srcLen
+					c.spans = c.spans[1:]
+				}
+				if n > 0 {
+					return n, consumed, nil
+				}
+			}
+		}
+		rn, err := src.Read(c.chunk)
+		c.buf = append(c.buf, c.chunk[:rn]...)
+		if rn == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, consumed, err
+		}
+	}
+}
+
+// detect detects the encoding of the file. detected remains false if more bytes are needed.
+func (c *converter) detect(head func() ([]byte, error)) error {
+	h := c.buf
+	if c.offset > 0 {
+		var err error
+		if h, err = head(); err != nil {
+			return err
+		}
+	}
+	undecided := func(bom []byte) bool {
+		// the head of the file at offset 0 may become the bom
+		return c.offset == 0 && len(h) < len(bom) && bytes.HasPrefix(bom, h)
+	}
+
+	if c.encoding == EncodingAuto {
+		for _, e := range bomEncodings {
+			if undecided(encodingSpecs[e].bom) {
+				return nil
+			}
+		}
+	}
+	encoding := detectEncoding(c.encoding, h)
+	spec := encodingSpecs[encoding]
+	if undecided(spec.bom) {
+		return nil
+	}
+	c.spec, c.t, c.bomLength = spec, nil, 0
+	c.sdelim = sourceDelimiter(encoding, c.delim)
+	if spec.transformer != nil {
+		c.t = spec.transformer()
+	}
+	if len(spec.bom) > 0 && bytes.HasPrefix(h, spec.bom) {
+		c.bomLength = len(spec.bom)
+	}
+	c.detected = true
+	return nil
+}
+
+// skipBOM skips the BOM at the head of the file, and returns the number of the skipped bytes
+func (c *converter) skipBOM() int {
+	if c.offset >= int64(c.bomLength) {
+		return 0
+	}
+	skip := int(int64(c.bomLength) - c.offset)
+	if skip > len(c.buf) {
+		skip = len(c.buf)
+	}
+	c.consume(skip)
+	return skip
+}
+
+// convert converts the complete characters in buf into the spans line by line.
+// The incomplete character at the end remains in buf.
+func (c *converter) convert() error {
+	for len(c.buf) > 0 {
+		line := c.buf
+		if i := indexDelimiter(c.buf, c.sdelim); i >= 0 {
+			line = c.buf[:i+len(c.sdelim)]
+		}
+		out, nSrc, err := c.transform(line)
+		if err != nil {
+			return err
+		}
+		if nSrc == 0 {
+			return nil
+		}
+		c.appendSpans(line[:nSrc], out)
+		c.consume(nSrc)
+	}
+	return nil
+}
+
+// transform converts the complete characters in src
+func (c *converter) transform(src []byte) (out []byte, nSrc int, err error) {
+	// a byte in the source encoding is converted into at most 3 bytes (U+FFFD)
+	out = make([]byte, 3*len(src)+utf8.UTFMax)
+	for {
+		c.t.Reset()
+		nDst, nSrc, err := c.t.Transform(out, src, false)
+		switch err {
+		case nil, transform.ErrShortSrc:
+			return out[:nDst], nSrc, nil
+		case transform.ErrShortDst:
+			out = make([]byte, 2*len(out))
+		default:
+			return nil, 0, err
+		}
+	}
+}
+
+// appendSpans appends out converted from src to the spans.
+// With EncodingErrorReport, the invalid byte sequences in src are split into the spans of the *EncodingError.
+func (c *converter) appendSpans(src, out []byte) {
+	offset := c.offset
+	for c.policy == EncodingErrorReport {
+		start, end, k, ok := c.invalid(src, out)
+		if !ok {
+			break
+		}
+		c.spans = append(c.spans,
+			span{out: out[:k], srcLen: start},
+			span{srcLen: end - start, err: &EncodingError{Offset: offset + int64(start), Bytes: append([]byte(nil), src[start:end]...)}},
+		)
+		src, out, offset = src[end:], out[k+len(replacementChar):], offset+int64(end)
+	}
+	c.spans = append(c.spans, span{out: out, srcLen: len(src)})
+}
+
+// invalid returns the index k of the first invalid byte sequence in out converted from src,
+// and the range of the invalid byte sequence in src.
+func (c *converter) invalid(src, out []byte) (start, end, k int, ok bool) {
+	for {
+		i := bytes.Index(out[k:], []byte(replacementChar))
+		if i < 0 {
+			return 0, 0, 0, false
+		}
+		k += i
+		start, end = c.sourceLength(src, k), c.sourceLength(src, k+len(replacementChar))
+		if !bytes.Equal(src[start:end], c.spec.replacement) {
+			return start, end, k, true
+		}
+		k += len(replacementChar)
+	}
+}
+
+// sourceLength returns the number of the bytes in src converted into the first k bytes.
+// k must be at the boundary of the characters.
+func (c *converter) sourceLength(src []byte, k int) int {
+	c.t.Reset()
+	_, nSrc, _ := c.t.Transform(make([]byte, k), src, false)
+	return nSrc
+}
+
+func (c *converter) consume(n int) {
+	c.buf = c.buf[n:]
+	c.offset += int64(n)
+	if len(c.buf) == 0 {
+		c.buf = nil
+	}
+}
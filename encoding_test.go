@@ -0,0 +1,221 @@
+package follow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestEncoding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		encoding Encoding
+		encoder  encoding.Encoding
+		bom      []byte
+	}{
+		{name: "Shift_JIS", encoding: EncodingShiftJIS, encoder: japanese.ShiftJIS},
+		{name: "EUC-JP", encoding: EncodingEUCJP, encoder: japanese.EUCJP},
+		{name: "UTF-16LE", encoding: EncodingUTF16LE, encoder: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), bom: []byte{0xff, 0xfe}},
+		{name: "UTF-16LE detected by BOM", encoding: EncodingAuto, encoder: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), bom: []byte{0xff, 0xfe}},
+		{name: "UTF-16BE detected by BOM", encoding: EncodingAuto, encoder: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), bom: []byte{0xfe, 0xff}},
+		{name: "UTF-8 detected by BOM", encoding: EncodingAuto, encoder: unicode.UTF8, bom: []byte{0xef, 0xbb, 0xbf}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			f, fileStat := td.CreateFile("test.log")
+			defer f.Close()
+
+			first := mustEncode(tt.encoder, "こんにちは\n")
+			second := mustEncode(tt.encoder, "世界\n")
+			f.Write(tt.bom)
+			f.Write(first)
+			// write the partial character
+			f.Write(second[:1])
+
+			positionFile := posfile.InMemory(fileStat, 0)
+			opts := []OptionFunc{WithPositionFile(positionFile), WithEncoding(tt.encoding), WithReadPollInterval(10 * time.Millisecond)}
+			lr := mustOpenLineReader(f.Name(), opts...)
+			wantReadLine(t, lr, "こんにちは", time.Second)
+			wantReadLineTimeout(t, lr, 50*time.Millisecond)
+			firstEnd := int64(len(tt.bom) + len(first))
+			wantPositionFile(t, lr.r, fileStat, firstEnd)
+			if g, w := lr.r.Position().Offset, firstEnd; g != w {
+				t.Errorf("read offset got %v, want %v", g, w)
+			}
+			lr.Close()
+
+			// resume from the offset of the positionFile
+			f.Write(second[1:])
+			lr = mustOpenLineReader(f.Name(), opts...)
+			defer lr.Close()
+			wantReadLine(t, lr, "世界", time.Second)
+			wantPositionFile(t, lr.r, fileStat, firstEnd+int64(len(second)))
+		})
+	}
+}
+
+func TestEncodingErrorPolicy(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Replace", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+		f.Write([]byte("foo\xffbar\n"))
+
+		lr := mustOpenLineReader(f.Name(), WithEncoding(EncodingShiftJIS), WithReadFromHead(true))
+		defer lr.Close()
+		wantReadLine(t, lr, "foo�bar", time.Second)
+	})
+
+	t.Run("Report", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		// the genuine U+FFFD is not reported
+		f.Write([]byte{'f', 0, 0xfd, 0xff, 0x00, 0xd8, 'b', 0, '\n', 0})
+
+		lr := mustOpenLineReader(f.Name(), WithEncoding(EncodingUTF16LE), WithEncodingErrorPolicy(EncodingErrorReport), WithReadFromHead(true))
+		defer lr.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := lr.ReadLine(ctx)
+		var encErr *EncodingError
+		if !errors.As(err, &encErr) {
+			t.Fatalf("err got %v, want *EncodingError", err)
+		}
+		if g, w := encErr.Offset, int64(4); g != w {
+			t.Errorf("offset got %v, want %v", g, w)
+		}
+		wantReadLine(t, lr, "f�b", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 10)
+	})
+}
+
+func TestEncodingSmallBuffer(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile("test.log")
+	defer f.Close()
+	content := "こんにちは\n世界\n\nfoo\n"
+	f.Write(mustEncode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), content))
+
+	r := mustOpenReader(f.Name(), WithEncoding(EncodingUTF16LE), WithReadFromHead(true))
+	defer r.Close()
+	// the converted bytes exceeding p are kept until the next Read
+	wantReadAll(t, iotest.OneByteReader(r), content)
+	wantPositionFile(t, r, fileStat, int64(len(mustEncode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), content))))
+}
+
+func TestEncodingStartPosition(t *testing.T) {
+	t.Parallel()
+
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	// U+0A05 contains the byte of '\n' in UTF-16LE
+	content := "2024-01-01T00:00:00Z foo\n2024-01-01T00:00:01Z \u0a05bar\n2024-01-01T00:00:02Z baz\n"
+	sjisContent := "2024-01-01T00:00:00Z こんにちは\n2024-01-01T00:00:01Z 世界\n2024-01-01T00:00:02Z baz\n"
+
+	tests := []struct {
+		name     string
+		encoding Encoding
+		encoder  encoding.Encoding
+		bom      []byte
+		content  string
+		opt      OptionFunc
+		want     string
+	}{
+		{name: "Last lines UTF-16LE", encoding: EncodingUTF16LE, encoder: utf16le, content: content, opt: WithStartFromLastLines(2), want: "2024-01-01T00:00:01Z \u0a05bar"},
+		{name: "Last lines from the BOM", encoding: EncodingAuto, encoder: utf16le, bom: []byte{0xff, 0xfe}, content: content, opt: WithStartFromLastLines(3), want: "2024-01-01T00:00:00Z foo"},
+		{name: "Time UTF-16LE", encoding: EncodingUTF16LE, encoder: utf16le, content: content, opt: WithStartFromTime(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC), nil), want: "2024-01-01T00:00:01Z \u0a05bar"},
+		{name: "Time Shift_JIS", encoding: EncodingShiftJIS, encoder: japanese.ShiftJIS, content: sjisContent, opt: WithStartFromTime(time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC), nil), want: "2024-01-01T00:00:01Z 世界"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			f, _ := td.CreateFile("test.log")
+			defer f.Close()
+			f.Write(tt.bom)
+			f.Write(mustEncode(tt.encoder, tt.content))
+
+			lr := mustOpenLineReader(f.Name(), WithEncoding(tt.encoding), tt.opt)
+			defer lr.Close()
+			wantReadLine(t, lr, tt.want, time.Second)
+		})
+	}
+
+	t.Run("Seek", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+		first := mustEncode(utf16le, "foo\n")
+		f.Write(first)
+		f.Write(mustEncode(utf16le, "\u0a05bar\n"))
+
+		lr := mustOpenLineReader(f.Name(), WithEncoding(EncodingUTF16LE), WithReadFromHead(true))
+		defer lr.Close()
+		// the offset inside the code unit is moved back to the head of the line
+		wantSeek(t, lr.r, int64(len(first)+3), io.SeekStart, int64(len(first)))
+		wantReadLine(t, lr, "\u0a05bar", time.Second)
+	})
+
+	t.Run("Non-ASCII delimiter", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+
+		if r, err := Open(f.Name(), WithEncoding(EncodingShiftJIS), WithLineDelimiter(0x82)); err == nil {
+			r.Close()
+			t.Error("err got nil, want the error of the lineDelimiter")
+		}
+	})
+}
+
+func mustEncode(e encoding.Encoding, s string) []byte {
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
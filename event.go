@@ -0,0 +1,85 @@
+package follow
+
+import (
+	"time"
+
+	"github.com/kei2100/follow/stat"
+)
+
+// EventType is the type of the Event
+type EventType int
+
+const (
+	// EventOpened is emitted when the follow.Reader is opened.
+	// If the file matching the positionFile is found by the rotatedFilePathPatterns, Rotated is true
+	EventOpened EventType = iota
+	// EventRotationDetected is emitted when the rotation of the followed file is detected
+	EventRotationDetected
+	// EventSwitchedFile is emitted when the reading is switched to the next file.
+	// Prev* fields hold the position of the file read before switching
+	EventSwitchedFile
+	// EventTruncated is emitted when the truncation of the followed file is detected.
+	// PrevOffset is the read offset before the truncation
+	EventTruncated
+	// EventPositionReset is emitted when the position recorded in the positionFile is discarded.
+	// Prev* fields hold the discarded position
+	EventPositionReset
+	// EventReadError is emitted when an error occurred while reading
+	EventReadError
+	// EventClosed is emitted when the follow.Reader is closed
+	EventClosed
+)
+
+var eventTypeNames = map[EventType]string{
+	EventOpened:           "Opened",
+	EventRotationDetected: "RotationDetected",
+	EventSwitchedFile:     "SwitchedFile",
+	EventTruncated:        "Truncated",
+	EventPositionReset:    "PositionReset",
+	EventReadError:        "ReadError",
+	EventClosed:           "Closed",
+}
+
+func (t EventType) String() string {
+	if s, ok := eventTypeNames[t]; ok {
+		return s
+	}
+	return "Unknown"
+}
+
+// Event is the lifecycle event of the follow.Reader
+type Event struct {
+	Type EventType
+	Time time.Time
+	// Path is the path of the file
+	Path string
+	// FileStat is the FileStat of the file
+	FileStat *stat.FileStat
+	// Offset is the offset in the file
+	Offset int64
+	// PrevPath, PrevFileStat and PrevOffset are the previous position. see EventType for details
+	PrevPath     string
+	PrevFileStat *stat.FileStat
+	PrevOffset   int64
+	// Rotated reports whether the file is found by the rotatedFilePathPatterns
+	Rotated bool
+	// LostBytes is the number of bytes that may have been lost by the truncation
+	LostBytes int64
+	// Err is the error occurred while reading
+	Err error
+}
+
+// EventHandler handles the Event.
+// EventHandler is called synchronously from the goroutines of the follow.Reader, so it should return quickly.
+type EventHandler func(ev Event)
+
+// emitEvent calls the handler if not nil
+func emitEvent(handler EventHandler, ev Event) {
+	if handler == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	handler(ev)
+}
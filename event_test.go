@@ -0,0 +1,143 @@
+package follow
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+	"github.com/kei2100/follow/stat"
+)
+
+func TestEventHandler(t *testing.T) {
+	t.Run("Rotate", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		name := "test.log"
+		rotated, rotatedStat := td.CreateFile(name + ".1")
+		rotated.WriteString("foo")
+		rotated.Close()
+
+		current, currentStat := td.CreateFile(name)
+		defer current.Close()
+		current.WriteString("bar")
+
+		var events eventRecorder
+		positionFile := posfile.InMemory(rotatedStat, 1)
+		r := mustOpenReader(
+			current.Name(),
+			WithPositionFile(positionFile),
+			WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+			WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+			WithEventHandler(events.handle),
+		)
+		wantRead(t, r, "oobar", 10*time.Millisecond, time.Second)
+
+		mustTruncate(current)
+		current.WriteString("b")
+		wantRead(t, r, "b", 10*time.Millisecond, time.Second)
+		r.Close()
+
+		got := events.get()
+		want := []EventType{EventOpened, EventRotationDetected, EventSwitchedFile, EventTruncated, EventClosed}
+		wantEventTypes(t, got, want)
+		if len(got) != len(want) {
+			return
+		}
+		if !got[0].Rotated || got[0].Path != rotated.Name() || got[0].Offset != 1 {
+			t.Errorf("opened event got %+v", got[0])
+		}
+		if ev := got[2]; ev.Path != current.Name() || ev.PrevPath != rotated.Name() || ev.PrevOffset != 3 || !stat.SameFile(ev.FileStat, currentStat) {
+			t.Errorf("switched event got %+v", ev)
+		}
+		if ev := got[3]; ev.PrevOffset != 3 || ev.Offset != 0 {
+			t.Errorf("truncated event got %+v", ev)
+		}
+		if ev := got[4]; ev.Path != current.Name() || ev.Offset != 1 {
+			t.Errorf("closed event got %+v", ev)
+		}
+	})
+
+	t.Run("Position reset", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foo")
+
+		var events eventRecorder
+		positionFile := posfile.InMemory(fileStat, 5)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithEventHandler(events.handle))
+		r.Close()
+
+		got := events.get()
+		wantEventTypes(t, got, []EventType{EventPositionReset, EventOpened, EventClosed})
+		if len(got) > 0 && (got[0].PrevOffset != 5 || got[0].Offset != 3) {
+			t.Errorf("position reset event got %+v", got[0])
+		}
+	})
+}
+
+func TestEventHandlerReadAfterClose(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, _ := td.CreateFile("test.log")
+	defer f.Close()
+
+	var events eventRecorder
+	r := mustOpenReader(f.Name(), WithEventHandler(events.handle))
+	r.Close()
+	if _, err := r.Read(make([]byte, 8)); err == nil {
+		t.Errorf("want error after closed")
+	}
+
+	// the read error during the shutdown is not reported
+	wantEventTypes(t, events.get(), []EventType{EventOpened, EventClosed})
+}
+
+type eventRecorder struct {
+	events []Event
+	mu     sync.Mutex
+}
+
+func (er *eventRecorder) handle(ev Event) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	er.events = append(er.events, ev)
+}
+
+func (er *eventRecorder) get() []Event {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	return append([]Event(nil), er.events...)
+}
+
+func wantEventTypes(t *testing.T, got []Event, want []EventType) {
+	t.Helper()
+
+	var types []EventType
+	for _, ev := range got {
+		types = append(types, ev.Type)
+	}
+	if len(types) != len(want) {
+		t.Errorf("event types got %v, want %v", types, want)
+		return
+	}
+	for i := range types {
+		if types[i] != want[i] {
+			t.Errorf("event types got %v, want %v", types, want)
+			return
+		}
+	}
+}
@@ -3,3 +3,7 @@ module github.com/kei2100/follow
 go 1.22
 
 require github.com/kei2100/filesharedelete v0.0.0-20210814234627-59643fb948be
+
+require github.com/klauspost/compress v1.17.11
+
+require golang.org/x/text v0.14.0
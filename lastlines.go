@@ -0,0 +1,59 @@
+package follow
+
+import (
+	"bytes"
+	"io"
+)
+
+// lastLinesBlockSize is the size of the block read backwards by offsetOfLastLines
+const lastLinesBlockSize = 4096
+
+// offsetOfLastLines returns the offset of the head of the last n lines in the first size bytes of f.
+// delim is the line delimiter encoded in the encoding of f, which is found only at the boundary of its length.
+// The delimiter at the end is considered as the end of the last line, like tail -n.
+func offsetOfLastLines(f io.ReaderAt, size int64, n int, delim []byte) (int64, error) {
+	unit := int64(len(delim))
+	// exclude the partial code unit at the end
+	end := size - size%unit
+	if n <= 0 || end == 0 {
+		return end, nil
+	}
+	// skip the delimiter terminating the last line
+	last := make([]byte, unit)
+	if _, err := f.ReadAt(last, end-unit); err != nil && err != io.EOF {
+		return 0, err
+	}
+	if bytes.Equal(last, delim) {
+		end -= unit
+	}
+	return headOfLine(f, end, n, delim)
+}
+
+// headOfLine returns the offset following the n-th delimiter searched backwards from end, or 0 if not found.
+// end must be at the boundary of the length of delim.
+func headOfLine(f io.ReaderAt, end int64, n int, delim []byte) (int64, error) {
+	unit := len(delim)
+	buf := make([]byte, lastLinesBlockSize)
+	found := 0
+	for end > 0 {
+		start := end - lastLinesBlockSize
+		if start < 0 {
+			start = 0
+		}
+		b := buf[:end-start]
+		if _, err := f.ReadAt(b, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(b) - unit; i >= 0; i -= unit {
+			if !bytes.Equal(b[i:i+unit], delim) {
+				continue
+			}
+			found++
+			if found == n {
+				return start + int64(i+unit), nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
@@ -0,0 +1,90 @@
+package follow
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestOffsetOfLastLines(t *testing.T) {
+	long := strings.Repeat("x", lastLinesBlockSize+10)
+	tests := []struct {
+		name    string
+		content string
+		n       int
+		want    string
+	}{
+		{name: "Trailing delimiter", content: "a\nb\nc\n", n: 2, want: "b\nc\n"},
+		{name: "No trailing delimiter", content: "a\nb\nc", n: 2, want: "b\nc"},
+		{name: "More than lines", content: "a\nb\n", n: 3, want: "a\nb\n"},
+		{name: "Empty lines", content: "a\n\n\n", n: 2, want: "\n\n"},
+		{name: "Empty file", content: "", n: 1, want: ""},
+		{name: "Across blocks", content: "a\n" + long + "\nb\n", n: 2, want: long + "\nb\n"},
+		{name: "Across blocks from head", content: long + "\n" + long + "\n", n: 2, want: long + "\n" + long + "\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			w, _ := td.CreateFile("test.log")
+			w.WriteString(tt.content)
+			w.Close()
+			f, err := os.Open(w.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			got, err := offsetOfLastLines(f, int64(len(tt.content)), tt.n, []byte{'\n'})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w := int64(len(tt.content) - len(tt.want)); got != w {
+				t.Errorf("offset got %d, want %d", got, w)
+			}
+		})
+	}
+}
+
+func TestStartFromLastLines(t *testing.T) {
+	t.Run("No prior state", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("a\nb\nc\n")
+
+		r := mustOpenReader(f.Name(), WithPositionFile(posfile.InMemory(nil, 0)), WithStartFromLastLines(2))
+		defer r.Close()
+
+		wantReadAll(t, r, "b\nc\n")
+		wantPositionFile(t, r, fileStat, 6)
+	})
+
+	t.Run("Prior state", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("a\nb\nc\n")
+
+		r := mustOpenReader(f.Name(), WithPositionFile(posfile.InMemory(fileStat, 1)), WithStartFromLastLines(2))
+		defer r.Close()
+
+		wantReadAll(t, r, "\nb\nc\n")
+		wantPositionFile(t, r, fileStat, 6)
+	})
+}
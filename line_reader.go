@@ -0,0 +1,197 @@
+package follow
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// OpenLineReader opens the named file and returns the follow.LineReader
+func OpenLineReader(name string, opts ...OptionFunc) (*LineReader, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	// the follow.LineReader commits the offset by itself
+	opts = append(append([]OptionFunc{}, opts...), WithManualCommit(true))
+	r, err := Open(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newLineReader(r, !opt.manualCommit), nil
+}
+
+// LineReader is a line-oriented follow.Reader.
+// Unlike follow.Reader, LineReader advances the positionFile offset
+// only to the end of the last line returned by ReadLine.
+// If WithManualCommit is enabled, LineReader does not advance the positionFile offset,
+// and the caller commits it by CommitUpTo.
+type LineReader struct {
+	r          *Reader
+	opt        optionLine
+	autoCommit bool
+	buf        []byte
+	segs       []segment
+	lineEnd    Position
+	mu         sync.Mutex
+}
+
+// segment is a chunk of bytes that read from the single file
+type segment struct {
+	// end is the end index of the segment in the LineReader buffer
+	end int
+	// pos is the Position just after the segment
+	pos Position
+	// start is the Position just before the segment
+	start Position
+	// readAt is the time the segment read
+	readAt time.Time
+}
+
+func newLineReader(r *Reader, autoCommit bool) *LineReader {
+	return &LineReader{
+		r:          r,
+		opt:        r.opt.optionLine,
+		autoCommit: autoCommit,
+		lineEnd:    r.Position(),
+	}
+}
+
+// ReadLine reads a line, not including the lineDelimiter and the trailing CR (if trimCR is enabled).
+// ReadLine blocks until a complete line is available, the partialLineFlushTimeout elapsed, or ctx is done.
+// ReadLine returns ctx.Err() if ctx is done, and io.EOF if the follow.LineReader is closed while waiting.
+func (lr *LineReader) ReadLine(ctx context.Context) ([]byte, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	chunk := make([]byte, 4096)
+	for {
+		if line, ok, err := lr.nextLine(); ok || err != nil {
+			return line, err
+		}
+
+		n, pos, err := lr.readChunk(ctx, chunk)
+		if n > 0 {
+			lr.buf = append(lr.buf, chunk[:n]...)
+			lr.segs = append(lr.segs, segment{end: len(lr.buf), pos: pos, start: lr.lastPosition(), readAt: time.Now()})
+		}
+		if err != nil {
+			if err == context.DeadlineExceeded && ctx.Err() == nil {
+				// partialLineFlushTimeout elapsed
+				continue
+			}
+			return nil, err
+		}
+	}
+}
+
+// readChunk reads bytes from the follow.Reader.
+// If the buffer has a partial line, readChunk waits no longer than the partialLineFlushTimeout.
+func (lr *LineReader) readChunk(ctx context.Context, p []byte) (int, Position, error) {
+	if lr.opt.partialLineFlushTimeout <= 0 || len(lr.segs) == 0 {
+		return lr.r.readContext(ctx, p)
+	}
+	ctx, cancel := context.WithDeadline(ctx, lr.segs[0].readAt.Add(lr.opt.partialLineFlushTimeout))
+	defer cancel()
+	return lr.r.readContext(ctx, p)
+}
+
+// nextLine returns a line in the buffer if available, and commits the end of the line.
+func (lr *LineReader) nextLine() ([]byte, bool, error) {
+	if len(lr.buf) == 0 {
+		return nil, false, nil
+	}
+	var line []byte
+	var consume int
+	if i := bytes.IndexByte(lr.buf, lr.opt.lineDelimiter); i >= 0 && (lr.opt.maxLineLength <= 0 || i <= lr.opt.maxLineLength) {
+		line, consume = lr.buf[:i], i+1
+		if lr.opt.trimCR && len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+	} else if lr.opt.maxLineLength > 0 && len(lr.buf) >= lr.opt.maxLineLength {
+		line, consume = lr.buf[:lr.opt.maxLineLength], lr.opt.maxLineLength
+	} else if lr.opt.partialLineFlushTimeout > 0 && time.Since(lr.segs[0].readAt) >= lr.opt.partialLineFlushTimeout {
+		line, consume = lr.buf, len(lr.buf)
+	} else {
+		return nil, false, nil
+	}
+
+	line = append([]byte(nil), line...)
+	pos := lr.positionAt(consume)
+	if lr.autoCommit {
+		if err := lr.r.fu.commit(pos); err != nil {
+			return nil, false, err
+		}
+	}
+	lr.lineEnd = pos
+	lr.consume(consume)
+	return line, true, nil
+}
+
+// positionAt returns the Position of the index i in the buffer.
+// If the encoding is converted, the bytes in the buffer do not correspond to the bytes of the file,
+// so the index inside the segment is at the start of the segment.
+func (lr *LineReader) positionAt(i int) Position {
+	for _, seg := range lr.segs {
+		if i == seg.end {
+			return seg.pos
+		}
+		if i < seg.end {
+			if lr.r.fu.conv != nil {
+				return seg.start
+			}
+			return Position{FileStat: seg.pos.FileStat, Offset: seg.pos.Offset - int64(seg.end-i)}
+		}
+	}
+	return lr.segs[len(lr.segs)-1].pos
+}
+
+// lastPosition returns the Position just after the bytes in the buffer
+func (lr *LineReader) lastPosition() Position {
+	if len(lr.segs) == 0 {
+		return lr.lineEnd
+	}
+	return lr.segs[len(lr.segs)-1].pos
+}
+
+// consume discards the first n bytes of the buffer
+func (lr *LineReader) consume(n int) {
+	lr.buf = lr.buf[n:]
+	segs := lr.segs[:0]
+	for _, seg := range lr.segs {
+		seg.end -= n
+		if seg.end > 0 {
+			segs = append(segs, seg)
+		}
+	}
+	lr.segs = segs
+	if len(lr.buf) == 0 {
+		lr.buf = nil
+	}
+}
+
+// Position returns the Position just after the last line returned by ReadLine.
+// The returned Position can be passed to CommitUpTo.
+func (lr *LineReader) Position() Position {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.lineEnd
+}
+
+// CommitUpTo commits the Position returned by Position to the positionFile.
+// CommitUpTo is intended to be used with WithManualCommit.
+func (lr *LineReader) CommitUpTo(pos Position) error {
+	return lr.r.CommitUpTo(pos)
+}
+
+// Stats returns the statistics of the underlying follow.Reader.
+// The bytes and the lines buffered by the follow.LineReader are counted as read.
+func (lr *LineReader) Stats() (Stats, error) {
+	return lr.r.Stats()
+}
+
+// Close closes the follow.LineReader.
+// The bytes that have not been returned by ReadLine are read again from the positionFile offset at the next open.
+func (lr *LineReader) Close() error {
+	return lr.r.Close()
+}
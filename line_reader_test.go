@@ -0,0 +1,216 @@
+package follow
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestLineReader(t *testing.T) {
+	t.Run("Complete lines", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		lr := mustOpenLineReader(f.Name(), WithReadPollInterval(10*time.Millisecond))
+		defer lr.Close()
+
+		f.WriteString("foo\r\nbar\nba")
+		wantReadLine(t, lr, "foo", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 5)
+		wantReadLine(t, lr, "bar", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 9)
+
+		// partial line is held
+		wantReadLineTimeout(t, lr, 50*time.Millisecond)
+		wantPositionFile(t, lr.r, fileStat, 9)
+
+		f.WriteString("z\n")
+		wantReadLine(t, lr, "baz", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 13)
+	})
+
+	t.Run("Resume from the last line", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		lr := mustOpenLineReader(f.Name(), WithPositionFile(positionFile), WithReadPollInterval(10*time.Millisecond))
+		f.WriteString("foo\nba")
+		wantReadLine(t, lr, "foo", time.Second)
+		wantReadLineTimeout(t, lr, 50*time.Millisecond)
+		lr.Close()
+
+		f.WriteString("r\n")
+		lr = mustOpenLineReader(f.Name(), WithPositionFile(positionFile), WithReadPollInterval(10*time.Millisecond))
+		defer lr.Close()
+		wantReadLine(t, lr, "bar", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 8)
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		lr := mustOpenLineReader(
+			f.Name(),
+			WithLineDelimiter(';'),
+			WithMaxLineLength(4),
+			WithTrimCR(false),
+			WithPartialLineFlushTimeout(50*time.Millisecond),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer lr.Close()
+
+		f.WriteString("a\r;foobar;ba")
+		wantReadLine(t, lr, "a\r", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 3)
+		wantReadLine(t, lr, "foob", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 7)
+		wantReadLine(t, lr, "ar", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 10)
+		wantReadLine(t, lr, "ba", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 12)
+	})
+
+	t.Run("Unlimited line length", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		lr := mustOpenLineReader(f.Name(), WithMaxLineLength(0), WithReadPollInterval(10*time.Millisecond))
+		defer lr.Close()
+
+		f.WriteString("foo\nbarbaz\n")
+		wantReadLine(t, lr, "foo", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 4)
+		wantReadLine(t, lr, "barbaz", time.Second)
+		wantPositionFile(t, lr.r, fileStat, 11)
+	})
+
+	t.Run("Across rotation", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		old, oldStat := td.CreateFile("test.log")
+		oldc := testutil.OnceCloser{C: old}
+		defer oldc.Close()
+
+		lr := mustOpenLineReader(old.Name(), WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0), WithReadPollInterval(10*time.Millisecond))
+		defer lr.Close()
+
+		old.WriteString("old\n")
+		wantReadLine(t, lr, "old", time.Second)
+		wantPositionFile(t, lr.r, oldStat, 4)
+
+		oldc.Close()
+		mustRename(old.Name(), old.Name()+".bk")
+		current, currentStat := td.CreateFile(filepath.Base(old.Name()))
+		defer current.Close()
+		current.WriteString("current\n")
+
+		wantReadLine(t, lr, "current", time.Second)
+		wantPositionFile(t, lr.r, currentStat, 8)
+	})
+}
+
+func TestLineReaderKeepOptions(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, _ := td.CreateFile("test.log")
+	defer f.Close()
+
+	// the spare capacity of the caller's slice must not be overwritten
+	opts := make([]OptionFunc, 1, 2)
+	opts[0] = WithReadFromHead(true)
+	lr := mustOpenLineReader(f.Name(), opts...)
+	defer lr.Close()
+	if opts[:2][1] != nil {
+		t.Errorf("the backing array of opts is modified")
+	}
+}
+
+func TestLineReaderManualCommit(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile("test.log")
+	defer f.Close()
+
+	lr := mustOpenLineReader(f.Name(), WithManualCommit(true), WithReadPollInterval(10*time.Millisecond))
+	defer lr.Close()
+
+	f.WriteString("foo\nbar\n")
+	wantReadLine(t, lr, "foo", time.Second)
+	token := lr.Position()
+	wantReadLine(t, lr, "bar", time.Second)
+	wantPositionFile(t, lr.r, fileStat, 0)
+
+	if err := lr.CommitUpTo(token); err != nil {
+		t.Fatalf("failed to commit %+v", err)
+	}
+	wantPositionFile(t, lr.r, fileStat, 4)
+}
+
+func mustOpenLineReader(name string, opt ...OptionFunc) *LineReader {
+	lr, err := OpenLineReader(name, opt...)
+	if err != nil {
+		panic(err)
+	}
+	return lr
+}
+
+func wantReadLine(t *testing.T, lr *LineReader, want string, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	line, err := lr.ReadLine(ctx)
+	if err != nil {
+		t.Errorf("failed to read line %+v", err)
+		return
+	}
+	if g, w := string(line), want; g != w {
+		t.Errorf("got %q, want %q", g, w)
+	}
+}
+
+func wantReadLineTimeout(t *testing.T, lr *LineReader, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	line, err := lr.ReadLine(ctx)
+	if g, w := err, context.DeadlineExceeded; g != w {
+		t.Errorf("err got %v, want %v. line %q", g, w, line)
+	}
+}
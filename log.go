@@ -0,0 +1,29 @@
+package follow
+
+import (
+	"log/slog"
+
+	"github.com/kei2100/follow/stat"
+)
+
+// the attributes of the log records
+
+func pathAttr(path string) slog.Attr {
+	return slog.String("path", path)
+}
+
+func inodeAttr(st *stat.FileStat) slog.Attr {
+	if st == nil {
+		return slog.Any("inode", nil)
+	}
+	_, ino := stat.ID(st)
+	return slog.Uint64("inode", ino)
+}
+
+func offsetAttr(offset int64) slog.Attr {
+	return slog.Int64("offset", offset)
+}
+
+func errAttr(err error) slog.Attr {
+	return slog.Any("error", err)
+}
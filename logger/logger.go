@@ -1,14 +1,20 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 	"sync"
 )
 
 var (
-	logger   Logger = log.New(os.Stderr, "", log.LstdFlags)
-	loggerMu sync.RWMutex
+	logger = slog.New(NewHandler(log.New(os.Stderr, "", log.LstdFlags), nil))
+	// installed is the Logger installed by Set. nil if the *slog.Logger is set by SetSlog or not changed
+	installed Logger
+	loggerMu  sync.RWMutex
 )
 
 // Logger interface
@@ -27,51 +33,162 @@ type Logger interface {
 	Fatalf(format string, v ...interface{})
 }
 
-// Set the logger
+// Set the logger.
+// Set sets the global *slog.Logger to slog.New(NewHandler(l, nil)), and Fatal, Fatalln and Fatalf call those of l
 func Set(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	logger = slog.New(NewHandler(l, nil))
+	installed = l
+}
+
+// SetSlog sets the global *slog.Logger
+func SetSlog(l *slog.Logger) {
 	loggerMu.Lock()
 	defer loggerMu.Unlock()
 	logger = l
+	installed = nil
 }
 
-// Print calls logger.Print
-func Print(v ...interface{}) {
+// installedLogger returns the Logger installed by Set, or nil
+func installedLogger() Logger {
 	loggerMu.RLock()
 	defer loggerMu.RUnlock()
-	logger.Print(v...)
+	return installed
 }
 
-// Println calls logger.Println
-func Println(v ...interface{}) {
+// Slog returns the global *slog.Logger
+func Slog() *slog.Logger {
 	loggerMu.RLock()
 	defer loggerMu.RUnlock()
-	logger.Println(v...)
+	return logger
+}
+
+// Default returns the *slog.Logger that always logs through the global *slog.Logger,
+// even if the global *slog.Logger is changed by SetSlog after Default is called
+func Default() *slog.Logger {
+	return slog.New(&globalHandler{})
+}
+
+// NewHandler returns the slog.Handler that prints the records in the text format through l.
+// It is the adapter for the Logger interface.
+func NewHandler(l Logger, opts *slog.HandlerOptions) slog.Handler {
+	o := slog.HandlerOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	replace := o.ReplaceAttr
+	o.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			// l prints the time
+			return slog.Attr{}
+		}
+		if replace != nil {
+			return replace(groups, a)
+		}
+		return a
+	}
+	return slog.NewTextHandler(printWriter{l: l}, &o)
+}
+
+type printWriter struct {
+	l Logger
+}
+
+func (w printWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > 0 && p[n-1] == '\n' {
+		p = p[:n-1]
+	}
+	w.l.Print(string(p))
+	return n, nil
+}
+
+// globalHandler is the slog.Handler that delegates to the handler of the global *slog.Logger
+type globalHandler struct {
+	// wrap applies WithAttrs and WithGroup to the handler of the global *slog.Logger
+	wrap []func(h slog.Handler) slog.Handler
+}
+
+func (h *globalHandler) handler() slog.Handler {
+	gh := Slog().Handler()
+	for _, fn := range h.wrap {
+		gh = fn(gh)
+	}
+	return gh
+}
+
+func (h *globalHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler().Enabled(ctx, level)
+}
+
+func (h *globalHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *globalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(gh slog.Handler) slog.Handler { return gh.WithAttrs(attrs) })
 }
 
-// Printf calls logger.Printf
+func (h *globalHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(gh slog.Handler) slog.Handler { return gh.WithGroup(name) })
+}
+
+func (h *globalHandler) with(fn func(gh slog.Handler) slog.Handler) slog.Handler {
+	wrap := append(append([]func(slog.Handler) slog.Handler{}, h.wrap...), fn)
+	return &globalHandler{wrap: wrap}
+}
+
+// Print logs v at the info level through the global *slog.Logger
+func Print(v ...interface{}) {
+	Slog().Info(fmt.Sprint(v...))
+}
+
+// Println logs v at the info level through the global *slog.Logger
+func Println(v ...interface{}) {
+	Slog().Info(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+// Printf logs v at the info level through the global *slog.Logger
 func Printf(format string, v ...interface{}) {
-	loggerMu.RLock()
-	defer loggerMu.RUnlock()
-	logger.Printf(format, v...)
+	Slog().Info(fmt.Sprintf(format, v...))
 }
 
-// Fatal calls logger.Fatal
+// Fatal calls Fatal of the Logger installed by Set.
+// Otherwise, Fatal logs v at the error level through the global *slog.Logger, and calls os.Exit(1).
+//
+// Deprecated: the library never calls Fatal.
 func Fatal(v ...interface{}) {
-	loggerMu.RLock()
-	defer loggerMu.RUnlock()
-	logger.Fatal(v...)
+	if l := installedLogger(); l != nil {
+		l.Fatal(v...)
+		return
+	}
+	Slog().Error(fmt.Sprint(v...))
+	os.Exit(1)
 }
 
-// Fatalln calls logger.Fatalln
+// Fatalln calls Fatalln of the Logger installed by Set.
+// Otherwise, Fatalln logs v at the error level through the global *slog.Logger, and calls os.Exit(1).
+//
+// Deprecated: the library never calls Fatalln.
 func Fatalln(v ...interface{}) {
-	loggerMu.RLock()
-	defer loggerMu.RUnlock()
-	logger.Fatalln(v...)
+	if l := installedLogger(); l != nil {
+		l.Fatalln(v...)
+		return
+	}
+	Slog().Error(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+	os.Exit(1)
 }
 
-// Fatalf calls logger.Fatalf
+// Fatalf calls Fatalf of the Logger installed by Set.
+// Otherwise, Fatalf logs v at the error level through the global *slog.Logger, and calls os.Exit(1).
+//
+// Deprecated: the library never calls Fatalf.
 func Fatalf(format string, v ...interface{}) {
-	loggerMu.RLock()
-	defer loggerMu.RUnlock()
-	logger.Fatalf(format, v...)
+	if l := installedLogger(); l != nil {
+		l.Fatalf(format, v...)
+		return
+	}
+	Slog().Error(fmt.Sprintf(format, v...))
+	os.Exit(1)
 }
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(NewHandler(log.New(&buf, "", 0), nil))
+	l.Warn("follow: reset", slog.String("path", "/tmp/test.log"), slog.Int64("offset", 3))
+
+	got := strings.TrimSpace(buf.String())
+	want := `level=WARN msg="follow: reset" path=/tmp/test.log offset=3`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	orig := Slog()
+	defer SetSlog(orig)
+
+	l := Default().With(slog.String("path", "/tmp/test.log"))
+	for i := 0; i < 2; i++ {
+		// the change of the global logger takes effect after Default is called
+		var buf bytes.Buffer
+		SetSlog(slog.New(NewHandler(log.New(&buf, "", 0), nil)))
+		l.Info(fmt.Sprintf("msg%d", i))
+
+		got := strings.TrimSpace(buf.String())
+		want := fmt.Sprintf("level=INFO msg=msg%d path=/tmp/test.log", i)
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// fatalLogger records the calls of Fatal instead of exiting
+type fatalLogger struct {
+	*log.Logger
+	fatals []string
+}
+
+func (l *fatalLogger) Fatal(v ...interface{})   { l.fatals = append(l.fatals, fmt.Sprint(v...)) }
+func (l *fatalLogger) Fatalln(v ...interface{}) { l.fatals = append(l.fatals, fmt.Sprint(v...)) }
+func (l *fatalLogger) Fatalf(format string, v ...interface{}) {
+	l.fatals = append(l.fatals, fmt.Sprintf(format, v...))
+}
+
+func TestFatalInstalledLogger(t *testing.T) {
+	orig := Slog()
+	defer SetSlog(orig)
+
+	var buf bytes.Buffer
+	l := &fatalLogger{Logger: log.New(&buf, "", 0)}
+	Set(l)
+	Fatal("foo")
+	Fatalln("bar")
+	Fatalf("baz %d", 1)
+
+	want := []string{"foo", "bar", "baz 1"}
+	if g, w := strings.Join(l.fatals, ","), strings.Join(want, ","); g != w {
+		t.Errorf("got %s, want %s", g, w)
+	}
+}
@@ -0,0 +1,229 @@
+// Package metrics exposes the metrics of the follow.Readers in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/logger"
+)
+
+// StatsReporter reports the follow.Stats. *follow.Reader implements StatsReporter
+type StatsReporter interface {
+	Stats() (follow.Stats, error)
+}
+
+// Collector collects the metrics of the registered follow.Readers labelled by the path.
+// The position flushes are labelled by the position file instead, since the follow.Readers may share the posfile.Store
+type Collector struct {
+	entries map[string]*entry
+	mu      sync.Mutex
+}
+
+type entry struct {
+	r           StatsReporter
+	truncations int64
+	errors      int64
+	// last is the last reported Stats. it is used after the follow.Reader is closed
+	last follow.Stats
+	// the counters of the Stats accumulated across the follow.Readers registered for the path
+	bytesRead, linesRead, rotations, positionFlushes counter
+}
+
+// observe records the Stats reported by the follow.Reader
+func (e *entry) observe(st follow.Stats) {
+	e.last = st
+	e.bytesRead.observe(st.BytesRead)
+	e.linesRead.observe(st.LinesRead)
+	e.rotations.observe(int64(st.Rotations))
+	e.positionFlushes.observe(st.PositionFlushes)
+}
+
+// counter accumulates the value counted since the follow.Reader is opened,
+// so that the value never goes backwards when the follow.Reader is reopened
+type counter struct {
+	base int64
+	last int64
+}
+
+func (c *counter) observe(v int64) {
+	if v < c.last {
+		// counted by the new follow.Reader
+		c.base += c.last
+	}
+	c.last = v
+}
+
+// restart accumulates the last value before the new follow.Reader starts counting from zero
+func (c *counter) restart() {
+	c.base += c.last
+	c.last = 0
+}
+
+func (c counter) value() int64 {
+	return c.base + c.last
+}
+
+// NewCollector creates the Collector
+func NewCollector() *Collector {
+	return &Collector{entries: make(map[string]*entry)}
+}
+
+// Register registers r labelled by the path.
+// To count the truncations and the errors, open r with follow.WithEventHandler(c.EventHandler(path)).
+// If r replaces the registered StatsReporter (e.g. the reopened follow.Reader), the counters continue from
+// the values of the replaced one.
+func (c *Collector) Register(path string, r StatsReporter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.entry(path)
+	if e.r != nil && e.r != r {
+		if st, err := e.r.Stats(); err == nil {
+			e.observe(st)
+		}
+		for _, ct := range []*counter{&e.bytesRead, &e.linesRead, &e.rotations, &e.positionFlushes} {
+			ct.restart()
+		}
+	}
+	e.r = r
+}
+
+// Unregister removes the metrics labelled by the path
+func (c *Collector) Unregister(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, path)
+}
+
+// EventHandler returns the follow.EventHandler that counts the truncations and the errors labelled by the path
+func (c *Collector) EventHandler(path string) follow.EventHandler {
+	return func(ev follow.Event) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		switch ev.Type {
+		case follow.EventTruncated:
+			c.entry(path).truncations++
+		case follow.EventReadError:
+			c.entry(path).errors++
+		}
+	}
+}
+
+// entry returns the entry for the path. c.mu must be held.
+func (c *Collector) entry(path string) *entry {
+	e, ok := c.entries[path]
+	if !ok {
+		e = &entry{}
+		c.entries[path] = e
+	}
+	return e
+}
+
+type metric struct {
+	name string
+	help string
+	typ  string
+	// label returns the name and the value of the label of e. the entries of the same label are reported once
+	label func(path string, e *entry) (string, string)
+	value func(e *entry) int64
+}
+
+func pathLabel(path string, _ *entry) (string, string) {
+	return "path", path
+}
+
+// positionFileLabel labels by the position file shared by the follow.Readers of the same posfile.Store.
+// the in-memory positionFile is not labelled
+func positionFileLabel(_ string, e *entry) (string, string) {
+	return "position_file", e.last.PositionFileName
+}
+
+var metricDefs = []metric{
+	{"follow_bytes_read_total", "Number of bytes read.", "counter", pathLabel, func(e *entry) int64 { return e.bytesRead.value() }},
+	{"follow_lines_read_total", "Number of lines read.", "counter", pathLabel, func(e *entry) int64 { return e.linesRead.value() }},
+	{"follow_rotations_total", "Number of the rotations followed.", "counter", pathLabel, func(e *entry) int64 { return e.rotations.value() }},
+	{"follow_truncations_total", "Number of the truncations detected.", "counter", pathLabel, func(e *entry) int64 { return e.truncations }},
+	{"follow_position_flushes_total", "Number of the writes of the position file.", "counter", positionFileLabel, func(e *entry) int64 { return e.positionFlushes.value() }},
+	{"follow_errors_total", "Number of the errors occurred while reading.", "counter", pathLabel, func(e *entry) int64 { return e.errors }},
+	{"follow_lag_bytes", "Number of bytes not read yet in the file currently read.", "gauge", pathLabel, func(e *entry) int64 { return e.last.LagBytes }},
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	paths := make([]string, 0, len(c.entries))
+	for path, e := range c.entries {
+		paths = append(paths, path)
+		if e.r == nil {
+			continue
+		}
+		st, err := e.r.Stats()
+		if err != nil {
+			// the follow.Reader may be closed. report the last Stats
+			continue
+		}
+		e.observe(st)
+	}
+	sort.Strings(paths)
+	entries := make([]entry, len(paths))
+	for i, path := range paths {
+		entries[i] = *c.entries[path]
+	}
+	c.mu.Unlock()
+
+	cw := &countWriter{w: bufio.NewWriter(w)}
+	for _, m := range metricDefs {
+		fmt.Fprintf(cw, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", m.name, m.typ)
+		written := make(map[string]bool)
+		for i, path := range paths {
+			name, value := m.label(path, &entries[i])
+			if value == "" || written[value] {
+				continue
+			}
+			written[value] = true
+			fmt.Fprintf(cw, "%s{%s=\"%s\"} %d\n", m.name, name, escapeLabel(value), m.value(&entries[i]))
+		}
+	}
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if _, err := c.WriteTo(w); err != nil {
+		logger.Default().Warn("metrics: failed to write the metrics", slog.Any("error", err))
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+type countWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile(`test"1".log`)
+	defer f.Close()
+	f.WriteString("foo\nbar\n")
+
+	c := NewCollector()
+	r, err := follow.Open(
+		f.Name(),
+		follow.WithPositionFile(posfile.InMemory(fileStat, 0)),
+		follow.WithEventHandler(c.EventHandler(f.Name())),
+		follow.WithWatchRotateInterval(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	c.Register(f.Name(), r)
+
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	// truncate
+	f.Truncate(0)
+	f.Seek(0, io.SeekStart)
+	f.WriteString("baz")
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := res.Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8"; g != w {
+		t.Errorf("Content-Type got %s, want %s", g, w)
+	}
+
+	label := strings.ReplaceAll(f.Name(), `"`, `\"`)
+	for _, want := range []string{
+		"# TYPE follow_bytes_read_total counter",
+		fmt.Sprintf(`follow_bytes_read_total{path="%s"} 11`, label),
+		fmt.Sprintf(`follow_lines_read_total{path="%s"} 2`, label),
+		fmt.Sprintf(`follow_rotations_total{path="%s"} 0`, label),
+		fmt.Sprintf(`follow_truncations_total{path="%s"} 1`, label),
+		fmt.Sprintf(`follow_errors_total{path="%s"} 0`, label),
+		"# TYPE follow_lag_bytes gauge",
+		fmt.Sprintf(`follow_lag_bytes{path="%s"} 0`, label),
+	} {
+		if !strings.Contains(string(b), want+"\n") {
+			t.Errorf("metrics does not contain %s. got:\n%s", want, b)
+		}
+	}
+}
+
+func TestCollectorSharedStore(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	storePath := filepath.Join(td.Path, "store")
+	s, err := posfile.OpenStore(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c := NewCollector()
+	for _, name := range []string{"foo.log", "bar.log"} {
+		f, _ := td.CreateFile(name)
+		defer f.Close()
+		f.WriteString("foo\n")
+		pf, _ := s.PositionFile(f.Name())
+		r, err := follow.Open(f.Name(), follow.WithPositionFile(pf), follow.WithReadFromHead(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		c.Register(f.Name(), r)
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var b strings.Builder
+	if _, err := c.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	// the flushes of the shared Store are reported once
+	want := fmt.Sprintf(`follow_position_flushes_total{position_file="%s"} %d`, storePath, s.FlushCount())
+	if g := strings.Count(b.String(), "follow_position_flushes_total{"); g != 1 {
+		t.Errorf("position flushes reported %d times. got:\n%s", g, b.String())
+	}
+	if !strings.Contains(b.String(), want+"\n") {
+		t.Errorf("metrics does not contain %s. got:\n%s", want, b.String())
+	}
+}
+
+func TestCollectorReopen(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile("test.log")
+	defer f.Close()
+	f.WriteString("foo\n")
+
+	c := NewCollector()
+	positionFile := posfile.InMemory(fileStat, 0)
+	open := func() *follow.Reader {
+		r, err := follow.Open(f.Name(), follow.WithPositionFile(positionFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Register(f.Name(), r)
+		if _, err := io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	wantMetric := func(want string) {
+		t.Helper()
+		var b strings.Builder
+		if _, err := c.WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Errorf("metrics does not contain %s. got:\n%s", want, b.String())
+		}
+	}
+
+	r := open()
+	wantMetric(fmt.Sprintf(`follow_bytes_read_total{path="%s"} 4`, f.Name()))
+	r.Close()
+
+	// the counters of the reopened follow.Reader continue from the closed one
+	f.WriteString("bar\n")
+	r = open()
+	defer r.Close()
+	wantMetric(fmt.Sprintf(`follow_bytes_read_total{path="%s"} 8`, f.Name()))
+	wantMetric(fmt.Sprintf(`follow_lines_read_total{path="%s"} 2`, f.Name()))
+}
@@ -0,0 +1,320 @@
+package follow
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kei2100/follow/posfile"
+)
+
+// OpenGlob opens the files matching the globPatterns and returns the follow.MultiReader.
+// Each file is followed by the follow.Reader opened with opts.
+func OpenGlob(globPatterns []string, opts ...OptionFunc) (*MultiReader, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	for _, glob := range globPatterns {
+		// validate patterns
+		if _, err := filepath.Match(glob, ""); err != nil {
+			return nil, err
+		}
+	}
+	mr := &MultiReader{
+		globPatterns: globPatterns,
+		opts:         opts,
+		opt:          opt,
+		readers:      make(map[string]*Reader),
+		gone:         make(map[string]bool),
+		parked:       make(map[string]posfile.PositionFile),
+		closed:       make(chan struct{}),
+		log:          opt.log(),
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.glob(opt.readFromHead)
+	return mr, nil
+}
+
+// Chunk is a chunk of bytes read by the follow.MultiReader
+type Chunk struct {
+	// Path is the path of the followed file
+	Path string
+	// Data is the read bytes
+	Data []byte
+	// Position is the Position just after Data. It can be passed to CommitUpTo
+	Position Position
+}
+
+// MultiReader is a reader that follows the files matching the glob patterns.
+// MultiReader re-evaluates the glob patterns every globInterval, starts following newly created files,
+// and stops following the files that disappeared after reading the remaining bytes.
+//
+// If the matched files exceed maxOpenFiles, the followed file that reached EOF is closed to give its slot to
+// the waiting file, and waits for the slot again. It is resumed from its positionFile like the restart,
+// so the bytes written to the file are lost if the file is removed or rotated out of the glob patterns while waiting.
+// The files that never reach EOF keep their slots.
+type MultiReader struct {
+	globPatterns []string
+	opts         []OptionFunc
+	opt          option
+	// paths are the followed paths in the order of reading
+	paths   []string
+	readers map[string]*Reader
+	// gone reports whether the followed path is no longer matched by the glob patterns
+	gone map[string]bool
+	// pending are the matched paths waiting for the free slot of maxOpenFiles
+	pending []string
+	// parked are the in-memory positionFiles of the pending paths closed to give their slots
+	parked map[string]posfile.PositionFile
+	// generation is incremented whenever the paths change
+	generation uint64
+	next       int
+	lastGlob   time.Time
+	closed     chan struct{}
+	log        *slog.Logger
+	mu         sync.Mutex
+}
+
+// ReadChunk reads up to len(p) bytes from one of the followed files.
+// The Data of the returned Chunk refers to p.
+// ReadChunk blocks until new bytes are available or ctx is done.
+// ReadChunk returns ctx.Err() if ctx is done, and io.EOF if the follow.MultiReader is closed while waiting.
+func (mr *MultiReader) ReadChunk(ctx context.Context, p []byte) (Chunk, error) {
+	if len(p) == 0 {
+		return Chunk{}, nil
+	}
+	var tick *time.Ticker
+	for {
+		select {
+		case <-mr.closed:
+			return Chunk{}, io.EOF
+		default:
+		}
+		if c, ok := mr.readAny(p); ok {
+			return c, nil
+		}
+		if tick == nil {
+			tick = time.NewTicker(mr.opt.readPollInterval)
+			defer tick.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return Chunk{}, ctx.Err()
+		case <-mr.closed:
+			return Chunk{}, io.EOF
+		case <-tick.C:
+		}
+	}
+}
+
+// readAny reads bytes from the followed files in round-robin order.
+func (mr *MultiReader) readAny(p []byte) (Chunk, bool) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if time.Since(mr.lastGlob) >= mr.opt.globInterval {
+		mr.glob(true)
+	}
+	var drained, eof []string
+	defer func() {
+		for _, path := range drained {
+			mr.unfollow(path)
+		}
+		mr.park(eof)
+	}()
+	for i := 0; i < len(mr.paths); i++ {
+		idx := (mr.next + i) % len(mr.paths)
+		path := mr.paths[idx]
+		n, pos, err := mr.readers[path].read(p)
+		if n > 0 {
+			mr.next = idx + 1
+			return Chunk{Path: path, Data: p[:n], Position: pos}, true
+		}
+		if err != nil && err != io.EOF {
+			mr.log.Error("follow: an error occurred while reading the file", pathAttr(path), errAttr(err))
+			continue
+		}
+		if mr.gone[path] {
+			// finished reading the file that disappeared
+			drained = append(drained, path)
+		} else {
+			eof = append(eof, path)
+		}
+	}
+	return Chunk{}, false
+}
+
+// glob re-evaluates the glob patterns. mr.mu must be held.
+func (mr *MultiReader) glob(readFromHead bool) {
+	mr.lastGlob = time.Now()
+
+	matched := make(map[string]bool)
+	for _, glob := range mr.globPatterns {
+		entries, err := filepath.Glob(glob)
+		if err != nil {
+			mr.log.Error("follow: failed to evaluate the glob pattern", slog.String("glob", glob), errAttr(err))
+			continue
+		}
+		for _, ent := range entries {
+			matched[ent] = true
+		}
+	}
+
+	for _, path := range mr.paths {
+		mr.gone[path] = !matched[path]
+	}
+	pending := mr.pending[:0]
+	for _, path := range mr.pending {
+		if matched[path] {
+			pending = append(pending, path)
+		} else {
+			delete(mr.parked, path)
+		}
+		delete(matched, path)
+	}
+	mr.pending = pending
+
+	var found []string
+	for path := range matched {
+		if _, ok := mr.readers[path]; !ok {
+			found = append(found, path)
+		}
+	}
+	sort.Strings(found)
+	mr.pending = append(mr.pending, found...)
+	mr.openPending(readFromHead)
+}
+
+// openPending starts following the pending paths as far as maxOpenFiles allows. mr.mu must be held.
+func (mr *MultiReader) openPending(readFromHead bool) {
+	for len(mr.pending) > 0 {
+		if mr.opt.maxOpenFiles > 0 && len(mr.paths) >= mr.opt.maxOpenFiles {
+			return
+		}
+		path := mr.pending[0]
+		mr.pending = mr.pending[1:]
+		r, err := mr.open(path, readFromHead)
+		if err != nil {
+			mr.log.Error("follow: failed to open the file", pathAttr(path), errAttr(err))
+			continue
+		}
+		mr.paths = append(mr.paths, path)
+		mr.generation++
+		mr.readers[path] = r
+		mr.gone[path] = false
+	}
+}
+
+func (mr *MultiReader) open(path string, readFromHead bool) (*Reader, error) {
+	opts := append([]OptionFunc{}, mr.opts...)
+	opts = append(opts, WithReadFromHead(readFromHead), WithPositionFile(nil))
+	if readFromHead {
+		// the files found after opened are read from the head
+		opts = append(opts, WithStartFromLastLines(0))
+	}
+	if pf, ok := mr.parked[path]; ok {
+		delete(mr.parked, path)
+		opts = append(opts, WithPositionFile(pf))
+	} else if mr.opt.positionFileFunc != nil {
+		pf, err := mr.opt.positionFileFunc(path)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPositionFile(pf))
+	}
+	return Open(path, opts...)
+}
+
+// unfollow stops following the path. mr.mu must be held.
+func (mr *MultiReader) unfollow(path string) {
+	mr.close(path)
+	mr.openPending(true)
+}
+
+// park stops following the paths reached EOF while the pending paths wait for the slot,
+// and queues them to wait for the slot again. mr.mu must be held.
+func (mr *MultiReader) park(eof []string) {
+	for _, path := range eof {
+		if len(mr.pending) == 0 || mr.opt.maxOpenFiles <= 0 || len(mr.paths) < mr.opt.maxOpenFiles {
+			return
+		}
+		r, ok := mr.readers[path]
+		if !ok || !r.fu.committedAll() {
+			// the uncommitted bytes are waiting for CommitUpTo
+			continue
+		}
+		if mr.opt.positionFileFunc == nil {
+			mr.parked[path] = r.fu.pf
+		}
+		mr.close(path)
+		mr.pending = append(mr.pending, path)
+		mr.openPending(true)
+	}
+}
+
+// close closes the follow.Reader of the path. mr.mu must be held.
+func (mr *MultiReader) close(path string) {
+	if err := mr.readers[path].Close(); err != nil {
+		mr.log.Error("follow: an error occurred while closing the file", pathAttr(path), errAttr(err))
+	}
+	delete(mr.readers, path)
+	delete(mr.gone, path)
+	for i, p := range mr.paths {
+		if p == path {
+			mr.paths = append(mr.paths[:i], mr.paths[i+1:]...)
+			mr.generation++
+			break
+		}
+	}
+}
+
+// CommitUpTo commits the Position of the Chunk read from the path to its positionFile.
+// CommitUpTo is intended to be used with WithManualCommit.
+// The Position of the path no longer followed is ignored, since the path is not read again.
+func (mr *MultiReader) CommitUpTo(path string, pos Position) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	r, ok := mr.readers[path]
+	if !ok {
+		return nil
+	}
+	return r.CommitUpTo(pos)
+}
+
+// Paths returns the paths currently followed
+func (mr *MultiReader) Paths() []string {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	return append([]string(nil), mr.paths...)
+}
+
+// PathsGeneration returns the number incremented whenever the paths currently followed change.
+// Comparing it is cheaper than Paths to know whether the paths changed.
+func (mr *MultiReader) PathsGeneration() uint64 {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	return mr.generation
+}
+
+// Close closes the follow.MultiReader and all the followed files.
+func (mr *MultiReader) Close() error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	close(mr.closed)
+	var err error
+	for _, path := range mr.paths {
+		if cErr := mr.readers[path].Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	mr.paths = nil
+	mr.readers = make(map[string]*Reader)
+	return err
+}
@@ -0,0 +1,211 @@
+package follow
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+	"github.com/kei2100/follow/stat"
+)
+
+func TestMultiReader(t *testing.T) {
+	t.Run("Follow new files", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		foo, _ := td.CreateFile("foo.log")
+		defer foo.Close()
+		foo.WriteString("skip")
+
+		mr := mustOpenGlob([]string{filepath.Join(td.Path, "*.log")}, WithGlobInterval(10*time.Millisecond), WithReadPollInterval(10*time.Millisecond))
+		defer mr.Close()
+
+		foo.WriteString("foo")
+		bar, _ := td.CreateFile("bar.log")
+		defer bar.Close()
+		bar.WriteString("bar")
+
+		wantReadChunks(t, mr, map[string]string{
+			foo.Name(): "foo",
+			bar.Name(): "bar",
+		}, time.Second)
+	})
+
+	t.Run("Max open files", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		foo, _ := td.CreateFile("foo.log")
+		fooc := testutil.OnceCloser{C: foo}
+		defer fooc.Close()
+		foo.WriteString("foo")
+		bar, _ := td.CreateFile("bar.log")
+		defer bar.Close()
+		bar.WriteString("bar")
+
+		mr := mustOpenGlob(
+			[]string{filepath.Join(td.Path, "*.log")},
+			WithReadFromHead(true),
+			WithMaxOpenFiles(1),
+			WithGlobInterval(10*time.Millisecond),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer mr.Close()
+
+		wantPaths(t, mr, []string{bar.Name()})
+		wantReadChunks(t, mr, map[string]string{bar.Name(): "bar"}, time.Second)
+
+		// stop following bar.log after drained, and start following foo.log
+		bar.WriteString("baz")
+		mustRemoveFile(bar.Name())
+		wantReadChunks(t, mr, map[string]string{bar.Name(): "baz", foo.Name(): "foo"}, time.Second)
+		wantPaths(t, mr, []string{foo.Name()})
+	})
+
+	t.Run("Give the slot of the file reached EOF", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		foo, _ := td.CreateFile("foo.log")
+		defer foo.Close()
+		foo.WriteString("foo")
+		bar, _ := td.CreateFile("bar.log")
+		defer bar.Close()
+		bar.WriteString("bar")
+
+		mr := mustOpenGlob(
+			[]string{filepath.Join(td.Path, "*.log")},
+			WithReadFromHead(true),
+			WithMaxOpenFiles(1),
+			WithGlobInterval(10*time.Millisecond),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer mr.Close()
+
+		// both live files are read in turn, and resumed from their positions
+		wantReadChunks(t, mr, map[string]string{bar.Name(): "bar", foo.Name(): "foo"}, time.Second)
+		bar.WriteString("baz")
+		foo.WriteString("qux")
+		wantReadChunks(t, mr, map[string]string{bar.Name(): "baz", foo.Name(): "qux"}, time.Second)
+	})
+
+	t.Run("Manual commit", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		foo, fooStat := td.CreateFile("foo.log")
+		defer foo.Close()
+		foo.WriteString("foo")
+
+		positionFile := posfile.InMemory(nil, 0)
+		mr := mustOpenGlob(
+			[]string{filepath.Join(td.Path, "*.log")},
+			WithReadFromHead(true),
+			WithManualCommit(true),
+			WithPositionFileFunc(func(path string) (posfile.PositionFile, error) { return positionFile, nil }),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer mr.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		c, err := mr.ReadChunk(ctx, make([]byte, 64))
+		if err != nil {
+			t.Fatalf("failed to read chunk %+v", err)
+		}
+		if g, w := positionFile.Offset(), int64(0); g != w {
+			t.Errorf("offset got %v, want %v", g, w)
+		}
+		if err := mr.CommitUpTo(c.Path, c.Position); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		if !stat.SameFile(positionFile.FileStat(), fooStat) || positionFile.Offset() != 3 {
+			t.Errorf("positionFile got %+v %v, want %+v 3", positionFile.FileStat(), positionFile.Offset(), fooStat)
+		}
+	})
+}
+
+func TestMultiReaderWithStore(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	foo, fooStat := td.CreateFile("foo.log")
+	defer foo.Close()
+
+	store, err := posfile.OpenStore(filepath.Join(td.Path, "store"))
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	defer store.Close()
+
+	mr := mustOpenGlob([]string{filepath.Join(td.Path, "*.log")}, WithPositionFileFunc(store.PositionFile), WithReadPollInterval(10*time.Millisecond))
+	defer mr.Close()
+
+	foo.WriteString("foo")
+	wantReadChunks(t, mr, map[string]string{foo.Name(): "foo"}, time.Second)
+
+	pf, _ := store.PositionFile(foo.Name())
+	if !stat.SameFile(pf.FileStat(), fooStat) {
+		t.Errorf("not same fileStat")
+	}
+	if g, w := pf.Offset(), int64(3); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+}
+
+func mustOpenGlob(globPatterns []string, opt ...OptionFunc) *MultiReader {
+	mr, err := OpenGlob(globPatterns, opt...)
+	if err != nil {
+		panic(err)
+	}
+	return mr
+}
+
+func wantReadChunks(t *testing.T, mr *MultiReader, want map[string]string, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	got := make(map[string]string)
+	var nGot, nWant int
+	for _, v := range want {
+		nWant += len(v)
+	}
+	for nGot < nWant {
+		c, err := mr.ReadChunk(ctx, make([]byte, 32))
+		if err != nil {
+			t.Errorf("failed to read chunk %+v. got %v, want %v", err, got, want)
+			return
+		}
+		got[c.Path] += string(c.Data)
+		nGot += len(c.Data)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func wantPaths(t *testing.T, mr *MultiReader, want []string) {
+	t.Helper()
+
+	got := mr.Paths()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("paths got %v, want %v", got, want)
+	}
+}
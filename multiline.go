@@ -0,0 +1,56 @@
+package follow
+
+import "regexp"
+
+// MultilineRule is the rule to aggregate the lines into the record.
+//
+// A line matching StartPattern always starts a new record.
+// If ContinuationPattern is nil, the lines not matching StartPattern are appended to the record.
+// If StartPattern is nil, the lines matching ContinuationPattern are appended to the record, and the others start new records.
+// If both are specified, the lines matching ContinuationPattern are appended only to the record started by the line matching StartPattern.
+// If both are nil, each line is the record.
+type MultilineRule struct {
+	// StartPattern matches the first line of the record
+	StartPattern *regexp.Regexp
+	// ContinuationPattern matches the lines following the first line of the record
+	ContinuationPattern *regexp.Regexp
+	// Negate inverts the matches of StartPattern and ContinuationPattern
+	Negate bool
+}
+
+// Presets of the MultilineRule
+var (
+	// JavaStackTraceRule aggregates the Java stack trace, including "Caused by" and "Suppressed",
+	// into the record of the preceding line
+	JavaStackTraceRule = MultilineRule{
+		ContinuationPattern: regexp.MustCompile(`^\s+(at |\.\.\. \d+ (more|common frames omitted))|^(Caused by|\s*Suppressed): |^([\w$]+\.)+[\w$]*(Exception|Error|Throwable)(: .*)?$`),
+	}
+	// PythonTracebackRule aggregates the Python traceback started by "Traceback (most recent call last):"
+	PythonTracebackRule = MultilineRule{
+		StartPattern:        regexp.MustCompile(`^Traceback \(most recent call last\):$`),
+		ContinuationPattern: regexp.MustCompile(`^\s|^$|^[\w.]+(Error|Exception|Warning|Exit|Interrupt|Iteration)\b|^(During handling of the above exception|The above exception was the direct cause)`),
+	}
+	// GoPanicRule aggregates the Go panic and the goroutine traces started by "panic:" or "fatal error:"
+	GoPanicRule = MultilineRule{
+		StartPattern:        regexp.MustCompile(`^(panic|fatal error): `),
+		ContinuationPattern: regexp.MustCompile(`^\s|^$|^goroutine \d+ \[|^\S+\(.*\)$|^created by |^\[(recovered|signal) |^\.\.\.|^exit status \d+$`),
+	}
+)
+
+// continues reports whether the line is appended to the record started by the first line
+func (rule MultilineRule) continues(first, line []byte) bool {
+	switch {
+	case rule.StartPattern != nil && rule.match(rule.StartPattern, line):
+		return false
+	case rule.ContinuationPattern == nil:
+		return rule.StartPattern != nil
+	case rule.StartPattern != nil && !rule.match(rule.StartPattern, first):
+		return false
+	default:
+		return rule.match(rule.ContinuationPattern, line)
+	}
+}
+
+func (rule MultilineRule) match(re *regexp.Regexp, line []byte) bool {
+	return re.Match(line) != rule.Negate
+}
@@ -1,52 +1,194 @@
 package follow
 
 import (
+	"log/slog"
 	"time"
 
+	"github.com/kei2100/follow/logger"
 	"github.com/kei2100/follow/posfile"
 )
 
 type option struct {
+	eventHandler            EventHandler
+	logger                  *slog.Logger
+	fingerprintSize         int64
+	fingerprintSizeSet      bool
 	rotatedFilePathPatterns []string
+	rotatedSortKey          RotatedSortKey
 	positionFile            posfile.PositionFile
 	readFromHead            bool
+	startFromLastLines      int
+	startFromTime           time.Time
+	timestampExtractor      TimestampExtractor
 	optionFollowRotate
+	optionRead
+	optionLine
+	optionRecord
+	optionMulti
 }
 
 type optionFollowRotate struct {
 	detectRotateDelay   time.Duration
 	followRotate        bool
+	watchMode           WatchMode
 	watchRotateInterval time.Duration
 }
 
+// WatchMode is the mode of watching the modification and the rotation of the followed file
+type WatchMode int
+
+const (
+	// WatchPolling polls the stat of the followed file every watchRotateInterval
+	WatchPolling WatchMode = iota
+	// WatchNotify watches the followed file by the notification of the OS (inotify on Linux).
+	// It falls back to WatchPolling if the notification is not available.
+	// The rotation is also checked every watchRotateInterval in case the notification misses it
+	WatchNotify
+)
+
+// RotatedSortKey is the key to order the rotated files from the oldest
+type RotatedSortKey int
+
+const (
+	// RotatedSortByModTime orders the rotated files by the modification time
+	RotatedSortByModTime RotatedSortKey = iota
+	// RotatedSortByNumericSuffix orders the rotated files by the numeric suffix.
+	// The larger suffix is older (e.g. app.log.2 is older than app.log.1)
+	RotatedSortByNumericSuffix
+	// RotatedSortByDateExt orders the rotated files by the date extension of logrotate dateext
+	// (e.g. app.log-20240101 is older than app.log-20240102)
+	RotatedSortByDateExt
+)
+
+type optionRead struct {
+	blockingRead        bool
+	encoding            Encoding
+	encodingErrorPolicy EncodingErrorPolicy
+	manualCommit        bool
+	readPollInterval    time.Duration
+	truncatePolicy      TruncatePolicy
+}
+
+// TruncatePolicy is the policy applied when the followed file is truncated
+type TruncatePolicy int
+
+const (
+	// TruncateReadFromHead reads the truncated file from the head
+	TruncateReadFromHead TruncatePolicy = iota
+	// TruncateReadFromEnd skips the bytes written to the truncated file before the truncation detected
+	TruncateReadFromEnd
+)
+
+type optionLine struct {
+	lineDelimiter           byte
+	maxLineLength           int
+	partialLineFlushTimeout time.Duration
+	trimCR                  bool
+}
+
+type optionRecord struct {
+	maxRecordBytes     int
+	maxRecordLines     int
+	multilineRule      MultilineRule
+	recordFlushTimeout time.Duration
+}
+
+type optionMulti struct {
+	globInterval     time.Duration
+	maxOpenFiles     int
+	positionFileFunc func(path string) (posfile.PositionFile, error)
+}
+
+// log returns the logger of the follow.Reader
+func (o *option) log() *slog.Logger {
+	if o.logger != nil {
+		return o.logger
+	}
+	return logger.Default()
+}
+
 // OptionFunc let you change follow.Reader behavior.
 type OptionFunc func(o *option)
 
 // Default values
 const (
-	DefaultDetectRotateDelay   = 5 * time.Second
-	DefaultFollowRotate        = true
-	DefaultReadFromHead        = false
-	DefaultWatchRotateInterval = 100 * time.Millisecond
+	DefaultBlockingRead            = false
+	DefaultDetectRotateDelay       = 5 * time.Second
+	DefaultEncoding                = EncodingNone
+	DefaultEncodingErrorPolicy     = EncodingErrorReplace
+	DefaultFingerprintSize         = int64(0)
+	DefaultRotatedFingerprintSize  = int64(1024)
+	DefaultFollowRotate            = true
+	DefaultGlobInterval            = time.Second
+	DefaultLineDelimiter           = byte('\n')
+	DefaultManualCommit            = false
+	DefaultMaxOpenFiles            = 0
+	DefaultMaxLineLength           = 1024 * 1024
+	DefaultMaxRecordBytes          = 1024 * 1024
+	DefaultMaxRecordLines          = 1000
+	DefaultPartialLineFlushTimeout = time.Duration(0)
+	DefaultReadFromHead            = false
+	DefaultReadPollInterval        = 100 * time.Millisecond
+	DefaultRecordFlushTimeout      = time.Second
+	DefaultRotatedSortKey          = RotatedSortByModTime
+	DefaultStartFromLastLines      = 0
+	DefaultTrimCR                  = true
+	DefaultTruncatePolicy          = TruncateReadFromHead
+	DefaultWatchMode               = WatchPolling
+	DefaultWatchRotateInterval     = 100 * time.Millisecond
 )
 
 func (o *option) apply(opts ...OptionFunc) {
+	o.blockingRead = DefaultBlockingRead
 	o.detectRotateDelay = DefaultDetectRotateDelay
+	o.encoding = DefaultEncoding
+	o.encodingErrorPolicy = DefaultEncodingErrorPolicy
+	o.fingerprintSize = DefaultFingerprintSize
 	o.followRotate = DefaultFollowRotate
+	o.globInterval = DefaultGlobInterval
+	o.lineDelimiter = DefaultLineDelimiter
+	o.manualCommit = DefaultManualCommit
+	o.maxOpenFiles = DefaultMaxOpenFiles
+	o.maxLineLength = DefaultMaxLineLength
+	o.maxRecordBytes = DefaultMaxRecordBytes
+	o.maxRecordLines = DefaultMaxRecordLines
+	o.partialLineFlushTimeout = DefaultPartialLineFlushTimeout
 	o.readFromHead = DefaultReadFromHead
+	o.readPollInterval = DefaultReadPollInterval
+	o.recordFlushTimeout = DefaultRecordFlushTimeout
+	o.rotatedSortKey = DefaultRotatedSortKey
+	o.startFromLastLines = DefaultStartFromLastLines
+	o.trimCR = DefaultTrimCR
+	o.truncatePolicy = DefaultTruncatePolicy
+	o.watchMode = DefaultWatchMode
 	o.watchRotateInterval = DefaultWatchRotateInterval
 	for _, fn := range opts {
 		fn(o)
 	}
+	if len(o.rotatedFilePathPatterns) > 0 && !o.fingerprintSizeSet {
+		// the compressed rotated files are matched only by the Fingerprint, since the compression changes the inode
+		o.fingerprintSize = DefaultRotatedFingerprintSize
+	}
 }
 
-// WithRotatedFilePathPatterns let you change rotatedFilePathPatterns
+// WithRotatedFilePathPatterns let you change rotatedFilePathPatterns.
+// The rotated files compressed in gzip or zstd are matched by the Fingerprint,
+// so the Fingerprint of DefaultRotatedFingerprintSize is enabled unless WithFingerprintSize is specified.
+// After reading the rotated file, the newer rotated files are read in order before the followed file
 func WithRotatedFilePathPatterns(globPatterns []string) OptionFunc {
 	return func(o *option) {
 		o.rotatedFilePathPatterns = globPatterns
 	}
 }
 
+// WithRotatedSortKey let you change rotatedSortKey.
+// rotatedSortKey orders the rotated files matching the rotatedFilePathPatterns
+func WithRotatedSortKey(v RotatedSortKey) OptionFunc {
+	return func(o *option) {
+		o.rotatedSortKey = v
+	}
+}
+
 // WithPositionFile let you change positionFile
 func WithPositionFile(positionFile posfile.PositionFile) OptionFunc {
 	return func(o *option) {
@@ -54,12 +196,13 @@ func WithPositionFile(positionFile posfile.PositionFile) OptionFunc {
 	}
 }
 
-// WithPositionFilePath let you change positionFile
-func WithPositionFilePath(path string) (OptionFunc, error) {
+// WithPositionFilePath let you change positionFile.
+// opts let you change the flush policy of the positionFile
+func WithPositionFilePath(path string, opts ...posfile.OptionFunc) (OptionFunc, error) {
 	if path == "" {
 		return WithPositionFile(nil), nil
 	}
-	pf, err := posfile.Open(path)
+	pf, err := posfile.Open(path, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -73,6 +216,34 @@ func WithDetectRotateDelay(v time.Duration) OptionFunc {
 	}
 }
 
+// WithEventHandler let you change eventHandler.
+// eventHandler receives the lifecycle Events of the follow.Reader such as the rotation and the truncation
+func WithEventHandler(handler EventHandler) OptionFunc {
+	return func(o *option) {
+		o.eventHandler = handler
+	}
+}
+
+// WithLogger let you change logger.
+// If not specified, the global logger set by logger.SetSlog is used
+func WithLogger(l *slog.Logger) OptionFunc {
+	return func(o *option) {
+		o.logger = l
+	}
+}
+
+// WithFingerprintSize let you change fingerprintSize.
+// If positive, the Fingerprint of the first fingerprintSize bytes is recorded in the positionFile,
+// and the file is identified by the Fingerprint in addition to the device and the inode to survive the inode reuse.
+// Zero disables the Fingerprint.
+// If not specified, DefaultRotatedFingerprintSize is used with the rotatedFilePathPatterns, otherwise DefaultFingerprintSize
+func WithFingerprintSize(v int64) OptionFunc {
+	return func(o *option) {
+		o.fingerprintSize = v
+		o.fingerprintSizeSet = true
+	}
+}
+
 // WithFollowRotate let you change followRotate
 func WithFollowRotate(follow bool) OptionFunc {
 	return func(o *option) {
@@ -87,9 +258,184 @@ func WithReadFromHead(v bool) OptionFunc {
 	}
 }
 
+// WithStartFromLastLines let you change startFromLastLines.
+// If positive, the reading starts from the last startFromLastLines lines delimited by the lineDelimiter, like tail -n.
+// It is applied only when the positionFile has no prior state, and takes precedence over readFromHead
+func WithStartFromLastLines(n int) OptionFunc {
+	return func(o *option) {
+		o.startFromLastLines = n
+	}
+}
+
+// WithStartFromTime let you change startFromTime and timestampExtractor.
+// If not zero, the reading starts from the first line whose timestamp extracted by the extractor is at or after since.
+// The file is binary-searched, so the timestamps must be in ascending order.
+// If the followed file starts after since, the rotated files matched by the rotatedFilePathPatterns are also searched.
+// If the extractor is nil, RFC3339TimestampExtractor is used.
+// It is applied only when the positionFile has no prior state, and takes precedence over startFromLastLines and readFromHead
+func WithStartFromTime(since time.Time, extractor TimestampExtractor) OptionFunc {
+	return func(o *option) {
+		o.startFromTime = since
+		o.timestampExtractor = extractor
+		if extractor == nil {
+			o.timestampExtractor = RFC3339TimestampExtractor
+		}
+	}
+}
+
+// WithWatchMode let you change watchMode.
+// With WatchNotify, a blocked Read also wakes up as soon as the followed file is modified
+func WithWatchMode(v WatchMode) OptionFunc {
+	return func(o *option) {
+		o.watchMode = v
+	}
+}
+
 // WithWatchRotateInterval let you change watchRotateInterval
 func WithWatchRotateInterval(v time.Duration) OptionFunc {
 	return func(o *option) {
 		o.watchRotateInterval = v
 	}
 }
+
+// WithBlockingRead let you change blockingRead.
+// If true, Read blocks until new bytes are available instead of returning io.EOF
+func WithBlockingRead(v bool) OptionFunc {
+	return func(o *option) {
+		o.blockingRead = v
+	}
+}
+
+// WithEncoding let you change encoding.
+// If not EncodingNone, the bytes of the file in the encoding are converted into UTF-8 by Read.
+// The offsets of the positionFile remain in the bytes of the file, and never land inside a character.
+// The lineDelimiter must be ASCII, which is also used to split the lines in the encoding
+func WithEncoding(v Encoding) OptionFunc {
+	return func(o *option) {
+		o.encoding = v
+	}
+}
+
+// WithEncodingErrorPolicy let you change encodingErrorPolicy.
+// encodingErrorPolicy is applied when the invalid byte sequence in the encoding is read
+func WithEncodingErrorPolicy(v EncodingErrorPolicy) OptionFunc {
+	return func(o *option) {
+		o.encodingErrorPolicy = v
+	}
+}
+
+// WithManualCommit let you change manualCommit.
+// If true, the positionFile is not updated by Read, but only by Commit or CommitUpTo
+func WithManualCommit(v bool) OptionFunc {
+	return func(o *option) {
+		o.manualCommit = v
+	}
+}
+
+// WithReadPollInterval let you change readPollInterval.
+// readPollInterval is the interval at which a blocked Read checks whether new bytes are available
+func WithReadPollInterval(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.readPollInterval = v
+	}
+}
+
+// WithLineDelimiter let you change lineDelimiter of the follow.LineReader
+func WithLineDelimiter(v byte) OptionFunc {
+	return func(o *option) {
+		o.lineDelimiter = v
+	}
+}
+
+// WithMaxLineLength let you change maxLineLength of the follow.LineReader.
+// A line longer than maxLineLength is split into multiple lines.
+// If maxLineLength <= 0, the length of the line is not limited
+func WithMaxLineLength(v int) OptionFunc {
+	return func(o *option) {
+		o.maxLineLength = v
+	}
+}
+
+// WithPartialLineFlushTimeout let you change partialLineFlushTimeout of the follow.LineReader.
+// A partial line that is not terminated by the lineDelimiter is returned as a line after partialLineFlushTimeout elapsed.
+// Zero means that the partial line is held until the lineDelimiter is written
+func WithPartialLineFlushTimeout(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.partialLineFlushTimeout = v
+	}
+}
+
+// WithTrimCR let you change trimCR of the follow.LineReader.
+// If true, a trailing CR of the line is removed
+func WithTrimCR(v bool) OptionFunc {
+	return func(o *option) {
+		o.trimCR = v
+	}
+}
+
+// WithMultilineRule let you change multilineRule of the follow.RecordReader.
+// multilineRule aggregates the lines into the record. If not specified, each line is the record
+func WithMultilineRule(rule MultilineRule) OptionFunc {
+	return func(o *option) {
+		o.multilineRule = rule
+	}
+}
+
+// WithMaxRecordLines let you change maxRecordLines of the follow.RecordReader.
+// A record reaching maxRecordLines is returned, and the following lines are aggregated into the next record.
+// Zero means no limit
+func WithMaxRecordLines(v int) OptionFunc {
+	return func(o *option) {
+		o.maxRecordLines = v
+	}
+}
+
+// WithMaxRecordBytes let you change maxRecordBytes of the follow.RecordReader.
+// A record is returned before it exceeds maxRecordBytes, and the following lines are aggregated into the next record.
+// Zero means no limit
+func WithMaxRecordBytes(v int) OptionFunc {
+	return func(o *option) {
+		o.maxRecordBytes = v
+	}
+}
+
+// WithRecordFlushTimeout let you change recordFlushTimeout of the follow.RecordReader.
+// The last record is returned if no line is appended within recordFlushTimeout.
+// Zero means that the last record is held until the next record starts
+func WithRecordFlushTimeout(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.recordFlushTimeout = v
+	}
+}
+
+// WithGlobInterval let you change globInterval of the follow.MultiReader.
+// globInterval is the interval at which the glob patterns are re-evaluated
+func WithGlobInterval(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.globInterval = v
+	}
+}
+
+// WithMaxOpenFiles let you change maxOpenFiles of the follow.MultiReader.
+// The files exceeding maxOpenFiles wait until another file stops being followed or reaches EOF. Zero means no limit
+func WithMaxOpenFiles(v int) OptionFunc {
+	return func(o *option) {
+		o.maxOpenFiles = v
+	}
+}
+
+// WithPositionFileFunc let you change positionFileFunc of the follow.MultiReader.
+// positionFileFunc returns the positionFile for the path. If not specified, in-memory positionFiles are used
+func WithPositionFileFunc(fn func(path string) (posfile.PositionFile, error)) OptionFunc {
+	return func(o *option) {
+		o.positionFileFunc = fn
+	}
+}
+
+// WithTruncatePolicy let you change truncatePolicy.
+// truncatePolicy is applied when the size of the followed file shrinks (e.g. logrotate copytruncate)
+func WithTruncatePolicy(v TruncatePolicy) OptionFunc {
+	return func(o *option) {
+		o.truncatePolicy = v
+	}
+}
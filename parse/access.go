@@ -0,0 +1,58 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accessLogPattern matches the Apache and Nginx combined log format. The referer and the user agent are optional for the common log format
+var accessLogPattern = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// accessLogTimeLayout is the layout of the time of the access log
+const accessLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// Combined parses the line of the Apache and Nginx combined log format, also the common log format.
+// The fields are remote_addr, ident, remote_user, time (time.Time), request, method, path, protocol,
+// status (int), body_bytes_sent (int64), referer and user_agent. The fields of "-" are omitted.
+func Combined(line []byte) (Fields, error) {
+	m := accessLogPattern.FindSubmatch(line)
+	if m == nil {
+		return nil, ErrUnmatched
+	}
+	fields := make(Fields)
+	setString := func(key string, v []byte) {
+		if len(v) > 0 && string(v) != "-" {
+			fields[key] = string(v)
+		}
+	}
+	setString("remote_addr", m[1])
+	setString("ident", m[2])
+	setString("remote_user", m[3])
+	t, err := time.Parse(accessLogTimeLayout, string(m[4]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid time: %w", err)
+	}
+	fields["time"] = t
+	setString("request", m[5])
+	if parts := strings.SplitN(string(m[5]), " ", 3); len(parts) == 3 {
+		fields["method"], fields["path"], fields["protocol"] = parts[0], parts[1], parts[2]
+	}
+	status, err := strconv.Atoi(string(m[6]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status: %w", err)
+	}
+	fields["status"] = status
+	if string(m[7]) != "-" {
+		size, err := strconv.ParseInt(string(m[7]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body_bytes_sent: %w", err)
+		}
+		fields["body_bytes_sent"] = size
+	}
+	setString("referer", m[8])
+	setString("user_agent", m[9])
+	return fields, nil
+}
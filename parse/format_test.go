@@ -0,0 +1,179 @@
+package parse
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParsers(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		parser  Parser
+		line    string
+		want    Fields
+		wantErr bool
+	}{
+		{
+			name:   "JSON",
+			parser: JSON,
+			line:   `{"level":"info","n":1.5,"nested":{"a":true}}`,
+			want:   Fields{"level": "info", "n": json.Number("1.5"), "nested": map[string]interface{}{"a": true}},
+		},
+		{name: "JSON array", parser: JSON, line: `[1]`, wantErr: true},
+		{name: "JSON trailing data", parser: JSON, line: `{} {}`, wantErr: true},
+		{
+			name:   "logfmt",
+			parser: Logfmt,
+			line:   `level=info msg="hello \"world\"" empty= flag elapsed=10ms`,
+			want:   Fields{"level": "info", "msg": `hello "world"`, "empty": "", "flag": "", "elapsed": "10ms"},
+		},
+		{name: "logfmt unterminated", parser: Logfmt, line: `msg="hello`, wantErr: true},
+		{name: "logfmt invalid key", parser: Logfmt, line: `=foo`, wantErr: true},
+		{
+			name:   "LTSV",
+			parser: LTSV,
+			line:   "host:127.0.0.1\tstatus:200\turl:http://example.com/",
+			want:   Fields{"host": "127.0.0.1", "status": "200", "url": "http://example.com/"},
+		},
+		{name: "LTSV invalid", parser: LTSV, line: "host:127.0.0.1\tfoo", wantErr: true},
+		{
+			name:   "Combined",
+			parser: Combined,
+			line:   `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`,
+			want: Fields{
+				"remote_addr": "127.0.0.1", "remote_user": "frank",
+				"time":    time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+				"request": "GET /apache_pb.gif HTTP/1.0", "method": "GET", "path": "/apache_pb.gif", "protocol": "HTTP/1.0",
+				"status": 200, "body_bytes_sent": int64(2326),
+				"referer": "http://www.example.com/start.html", "user_agent": "Mozilla/4.08 [en] (Win98; I ;Nav)",
+			},
+		},
+		{
+			name:   "Common",
+			parser: Combined,
+			line:   `::1 - - [10/Oct/2000:13:55:36 +0000] "-" 400 -`,
+			want: Fields{
+				"remote_addr": "::1", "time": time.Date(2000, 10, 10, 13, 55, 36, 0, time.UTC), "status": 400,
+			},
+		},
+		{name: "Combined unmatched", parser: Combined, line: `foo`, wantErr: true},
+		{
+			name:   "RFC3164",
+			parser: RFC3164,
+			line:   `<34>Jan  2 03:04:05 mymachine su[123]: 'su root' failed`,
+			want: Fields{
+				"priority": 34, "facility": 4, "severity": 2,
+				"timestamp": time.Date(rfc3164Year(now, time.January), time.January, 2, 3, 4, 5, 0, time.Local),
+				"hostname":  "mymachine", "app_name": "su", "procid": "123", "message": "'su root' failed",
+			},
+		},
+		{
+			name:   "RFC3164 without PRI and tag",
+			parser: RFC3164,
+			line:   `Jan  2 03:04:05 mymachine hello world`,
+			want: Fields{
+				"timestamp": time.Date(rfc3164Year(now, time.January), time.January, 2, 3, 4, 5, 0, time.Local),
+				"hostname":  "mymachine", "message": "hello world",
+			},
+		},
+		{
+			name:   "RFC5424",
+			parser: RFC5424,
+			line:   `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication\]"][examplePriority@32473 class="high"] ` + "\ufeff" + `An application event`,
+			want: Fields{
+				"priority": 165, "facility": 20, "severity": 5, "version": 1,
+				"timestamp": time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+				"hostname":  "mymachine.example.com", "app_name": "evntslog", "msgid": "ID47",
+				"structured_data": map[string]map[string]string{
+					"exampleSDID@32473":     {"iut": "3", "eventSource": `App"lication]`},
+					"examplePriority@32473": {"class": "high"},
+				},
+				"message": "An application event",
+			},
+		},
+		{
+			name:   "RFC5424 without structured data and message",
+			parser: RFC5424,
+			line:   `<13>1 - - - - - -`,
+			want:   Fields{"priority": 13, "facility": 1, "severity": 5, "version": 1},
+		},
+		{name: "RFC5424 invalid structured data", parser: RFC5424, line: `<13>1 - - - - - [foo bar]`, wantErr: true},
+		{
+			name:   "Regexp",
+			parser: Regexp(regexp.MustCompile(`^(?P<level>\w+): (?P<msg>.*?)(?: \((?P<code>\d+)\))?$`)),
+			line:   `ERROR: failed`,
+			want:   Fields{"level": "ERROR", "msg": "failed"},
+		},
+		{name: "Regexp unmatched", parser: Regexp(regexp.MustCompile(`^\d+$`)), line: `foo`, wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.parser([]byte(tt.line))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("err got nil, fields %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse %+v", err)
+			}
+			for k, v := range got {
+				if w, ok := tt.want[k].(time.Time); ok {
+					if g, ok := v.(time.Time); ok && g.Equal(w) {
+						got[k] = w
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmatched(t *testing.T) {
+	t.Parallel()
+
+	for _, parser := range []Parser{Combined, RFC3164, RFC5424, Regexp(regexp.MustCompile(`^$`))} {
+		if _, err := parser([]byte("foo")); !errors.Is(err, ErrUnmatched) {
+			t.Errorf("err got %v, want %v", err, ErrUnmatched)
+		}
+	}
+}
+
+func TestRFC3164Time(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local)
+	for s, want := range map[string]time.Time{
+		"Jan  1 00:00:00": time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local),
+		"Dec 31 23:59:59": time.Date(2023, time.December, 31, 23, 59, 59, 0, time.Local),
+	} {
+		got, err := rfc3164Time(s, now)
+		if err != nil {
+			t.Fatalf("failed to parse %+v", err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s got %v, want %v", s, got, want)
+		}
+	}
+}
+
+// rfc3164Year returns the year of the timestamp of the month parsed at now
+func rfc3164Year(now time.Time, month time.Month) int {
+	if time.Date(now.Year(), month, 1, 0, 0, 0, 0, time.Local).After(now.AddDate(0, 1, 0)) {
+		return now.Year() - 1
+	}
+	return now.Year()
+}
@@ -0,0 +1,25 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// JSON parses the line of JSON Lines. The line must be the JSON object.
+// The numbers are parsed into json.Number.
+func JSON(line []byte) (Fields, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var fields Fields
+	if err := dec.Decode(&fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON object")
+	}
+	return fields, nil
+}
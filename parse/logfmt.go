@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Logfmt parses the line of logfmt (e.g. level=info msg="hello world" elapsed=10ms).
+// The values are strings, and the key without the value has the empty string.
+func Logfmt(line []byte) (Fields, error) {
+	fields := make(Fields)
+	s := line
+	for {
+		s = bytes.TrimLeft(s, " \t")
+		if len(s) == 0 {
+			break
+		}
+		i := bytes.IndexAny(s, " \t=")
+		if i < 0 {
+			i = len(s)
+		}
+		key := string(s[:i])
+		if key == "" || bytes.ContainsRune(s[:i], '"') {
+			return nil, fmt.Errorf("invalid key at %q", s)
+		}
+		s = s[i:]
+		if len(s) == 0 || s[0] != '=' {
+			fields[key] = ""
+			continue
+		}
+		s = s[1:]
+		value, rest, err := logfmtValue(s)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = value
+		s = rest
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("no fields")
+	}
+	return fields, nil
+}
+
+// logfmtValue returns the value at the head of s, and the rest of s
+func logfmtValue(s []byte) (string, []byte, error) {
+	if len(s) == 0 || s[0] != '"' {
+		i := bytes.IndexAny(s, " \t")
+		if i < 0 {
+			i = len(s)
+		}
+		return string(s[:i]), s[i:], nil
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			v, err := strconv.Unquote(string(s[:i+1]))
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid quoted value %s: %w", s[:i+1], err)
+			}
+			return v, s[i+1:], nil
+		}
+	}
+	return "", nil, fmt.Errorf("unterminated quoted value %s", s)
+}
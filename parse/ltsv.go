@@ -0,0 +1,24 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// LTSV parses the line of Labeled Tab-separated Values (e.g. host:127.0.0.1<TAB>status:200).
+// The values are strings.
+func LTSV(line []byte) (Fields, error) {
+	if len(line) == 0 {
+		return nil, errors.New("no fields")
+	}
+	fields := make(Fields)
+	for _, field := range bytes.Split(line, []byte{'\t'}) {
+		i := bytes.IndexByte(field, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+		fields[string(field[:i])] = string(field[i+1:])
+	}
+	return fields, nil
+}
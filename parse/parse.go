@@ -0,0 +1,135 @@
+// Package parse parses the lines read by the follow.LineReader into the structured records.
+package parse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/logger"
+	"github.com/kei2100/follow/stat"
+)
+
+// ErrUnmatched is the cause of the Error when the line does not match the format
+var ErrUnmatched = errors.New("the line does not match the format")
+
+// Fields are the fields parsed from the line
+type Fields map[string]interface{}
+
+// Parser parses the line into the Fields
+type Parser func(line []byte) (Fields, error)
+
+// Record is the structured record parsed from the line
+type Record struct {
+	// Path is the path of the followed file
+	Path string
+	// Inode is the inode of the file the line read from
+	Inode uint64
+	// Offset is the offset of the head of the line in the file
+	Offset int64
+	// End is the Position just after the line. It can be passed to CommitUpTo of the follow.LineReader
+	End follow.Position
+	// Line is the raw line
+	Line []byte
+	// Fields are the parsed fields
+	Fields Fields
+}
+
+// Error is the error occurred while parsing the line
+type Error struct {
+	Path   string
+	Inode  uint64
+	Offset int64
+	Line   []byte
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("parse: failed to parse the line at offset %d of %s: %v", e.Offset, e.Path, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler handles the Error. The line failed to parse is skipped after ErrorHandler returns
+type ErrorHandler func(err *Error)
+
+// LineReader reads the lines. *follow.LineReader implements LineReader
+type LineReader interface {
+	ReadLine(ctx context.Context) ([]byte, error)
+	Position() follow.Position
+}
+
+// Reader reads the Records from the LineReader
+type Reader struct {
+	path         string
+	lr           LineReader
+	parser       Parser
+	errorHandler ErrorHandler
+	// end is the Position just after the last line read
+	end follow.Position
+	mu  sync.Mutex
+}
+
+// NewReader creates the Reader that parses the lines read from lr by the parser.
+// path is the path of the file followed by lr.
+// The lines failed to parse are passed to the errorHandler and skipped. If the errorHandler is nil, they are logged.
+func NewReader(path string, lr LineReader, parser Parser, errorHandler ErrorHandler) *Reader {
+	return &Reader{
+		path:         path,
+		lr:           lr,
+		parser:       parser,
+		errorHandler: errorHandler,
+		end:          lr.Position(),
+	}
+}
+
+// ReadRecord reads the line and returns the Record parsed from it.
+// ReadRecord returns the error returned by ReadLine of the LineReader, but never returns the Error of the parser.
+func (r *Reader) ReadRecord(ctx context.Context) (Record, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		line, err := r.lr.ReadLine(ctx)
+		if err != nil {
+			return Record{}, err
+		}
+		rec := r.record(line)
+		fields, err := r.parser(line)
+		if err != nil {
+			r.handleError(&Error{Path: rec.Path, Inode: rec.Inode, Offset: rec.Offset, Line: line, Err: err})
+			continue
+		}
+		rec.Fields = fields
+		return rec, nil
+	}
+}
+
+// record returns the Record of the line just read
+func (r *Reader) record(line []byte) Record {
+	start, end := r.end, r.lr.Position()
+	r.end = end
+	rec := Record{Path: r.path, End: end, Line: line}
+	if end.FileStat == nil {
+		return rec
+	}
+	_, rec.Inode = stat.ID(end.FileStat)
+	if start.FileStat != nil && stat.SameFile(start.FileStat, end.FileStat) {
+		rec.Offset = start.Offset
+	}
+	return rec
+}
+
+func (r *Reader) handleError(err *Error) {
+	if r.errorHandler != nil {
+		r.errorHandler(err)
+		return
+	}
+	logger.Default().Warn("parse: failed to parse the line",
+		slog.String("path", err.Path), slog.Uint64("inode", err.Inode), slog.Int64("offset", err.Offset), slog.Any("error", err.Err))
+}
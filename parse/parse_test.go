@@ -0,0 +1,83 @@
+package parse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+	"github.com/kei2100/follow/stat"
+)
+
+func TestReader(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile("test.log")
+	defer f.Close()
+	f.WriteString("{\"a\":1}\nbroken\n{\"b\":\"c\"}\n")
+
+	lr, err := follow.OpenLineReader(f.Name(), follow.WithPositionFile(posfile.InMemory(fileStat, 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lr.Close()
+
+	var parseErrs []*Error
+	r := NewReader(f.Name(), lr, JSON, func(err *Error) {
+		parseErrs = append(parseErrs, err)
+	})
+	_, ino := stat.ID(fileStat)
+
+	rec := mustReadRecord(t, r)
+	if g, w := rec.Fields["a"], json.Number("1"); g != w {
+		t.Errorf("fields got %v", rec.Fields)
+	}
+	wantRecordPosition(t, rec, f.Name(), ino, 0, 8)
+
+	// the broken line is routed to the error handler
+	rec = mustReadRecord(t, r)
+	if g, w := rec.Fields["b"], "c"; g != w {
+		t.Errorf("fields got %v", rec.Fields)
+	}
+	wantRecordPosition(t, rec, f.Name(), ino, 15, 25)
+
+	if g, w := len(parseErrs), 1; g != w {
+		t.Fatalf("len(errors) got %v, want %v", g, w)
+	}
+	if pe := parseErrs[0]; pe.Offset != 8 || string(pe.Line) != "broken" || pe.Inode != ino || pe.Path != f.Name() {
+		t.Errorf("error got %+v", pe)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.ReadRecord(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func mustReadRecord(t *testing.T, r *Reader) Record {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rec, err := r.ReadRecord(ctx)
+	if err != nil {
+		t.Fatalf("failed to read record %+v", err)
+	}
+	return rec
+}
+
+func wantRecordPosition(t *testing.T, rec Record, path string, inode uint64, offset, end int64) {
+	t.Helper()
+
+	if rec.Path != path || rec.Inode != inode || rec.Offset != offset || rec.End.Offset != end {
+		t.Errorf("record position got %s %d %d %d, want %s %d %d %d", rec.Path, rec.Inode, rec.Offset, rec.End.Offset, path, inode, offset, end)
+	}
+}
@@ -0,0 +1,23 @@
+package parse
+
+import "regexp"
+
+// Regexp returns the Parser that parses the line by re.
+// The fields are the submatches of the named groups in re. The unmatched groups are omitted.
+func Regexp(re *regexp.Regexp) Parser {
+	names := re.SubexpNames()
+	return func(line []byte) (Fields, error) {
+		m := re.FindSubmatchIndex(line)
+		if m == nil {
+			return nil, ErrUnmatched
+		}
+		fields := make(Fields)
+		for i, name := range names {
+			if name == "" || m[2*i] < 0 {
+				continue
+			}
+			fields[name] = string(line[m[2*i]:m[2*i+1]])
+		}
+		return fields, nil
+	}
+}
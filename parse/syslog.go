@@ -0,0 +1,183 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	rfc3164Pattern = regexp.MustCompile(`^(?:<(\d{1,3})>)?([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (\S+) (?:([^\s:\[]+)(?:\[([^\]]*)\])?: ?)?(.*)$`)
+	rfc5424Pattern = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S+) (\S+) (\S+) (\S+) (.*)$`)
+)
+
+// utf8BOM is the BOM at the head of the MSG of RFC 5424
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// RFC3164 parses the line of the BSD syslog format (e.g. <34>Oct 11 22:14:15 host su[123]: message).
+// The PRI is optional, as in the files written by the syslog daemons.
+// The fields are priority (int), facility (int), severity (int), timestamp (time.Time), hostname, app_name, procid and message.
+// The year of the timestamp is guessed from the current time in the local time zone.
+func RFC3164(line []byte) (Fields, error) {
+	m := rfc3164Pattern.FindSubmatch(line)
+	if m == nil {
+		return nil, ErrUnmatched
+	}
+	fields := make(Fields)
+	if len(m[1]) > 0 {
+		if err := setPriority(fields, m[1]); err != nil {
+			return nil, err
+		}
+	}
+	t, err := rfc3164Time(string(m[2]), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	fields["timestamp"] = t
+	fields["hostname"] = string(m[3])
+	if len(m[4]) > 0 {
+		fields["app_name"] = string(m[4])
+	}
+	if len(m[5]) > 0 {
+		fields["procid"] = string(m[5])
+	}
+	fields["message"] = string(m[6])
+	return fields, nil
+}
+
+// rfc3164Time parses the timestamp without the year. The timestamp is in the year of now,
+// or the previous year if it is more than a month after now (e.g. the log of Dec 31 read on Jan 1).
+func rfc3164Time(s string, now time.Time) (time.Time, error) {
+	t, err := time.ParseInLocation(time.Stamp, s, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.AddDate(0, 1, 0)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t, nil
+}
+
+// RFC5424 parses the line of the syslog protocol format
+// (e.g. <165>1 2003-10-11T22:14:15.003Z host app 1234 ID47 [exampleSDID@32473 iut="3"] message).
+// The fields are priority (int), facility (int), severity (int), version (int), timestamp (time.Time), hostname, app_name,
+// procid, msgid, structured_data (map[string]map[string]string) and message. The fields of the NILVALUE "-" are omitted.
+func RFC5424(line []byte) (Fields, error) {
+	m := rfc5424Pattern.FindSubmatch(line)
+	if m == nil {
+		return nil, ErrUnmatched
+	}
+	fields := make(Fields)
+	if err := setPriority(fields, m[1]); err != nil {
+		return nil, err
+	}
+	version, err := strconv.Atoi(string(m[2]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version: %w", err)
+	}
+	fields["version"] = version
+	if string(m[3]) != "-" {
+		t, err := time.Parse(time.RFC3339Nano, string(m[3]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %w", err)
+		}
+		fields["timestamp"] = t
+	}
+	for i, key := range []string{"hostname", "app_name", "procid", "msgid"} {
+		if v := string(m[4+i]); v != "-" {
+			fields[key] = v
+		}
+	}
+	sd, rest, err := structuredData(m[8])
+	if err != nil {
+		return nil, fmt.Errorf("invalid structured data: %w", err)
+	}
+	if sd != nil {
+		fields["structured_data"] = sd
+	}
+	if len(rest) > 0 {
+		if rest[0] != ' ' {
+			return nil, fmt.Errorf("invalid structured data: unexpected %q", rest)
+		}
+		fields["message"] = string(bytes.TrimPrefix(rest[1:], utf8BOM))
+	}
+	return fields, nil
+}
+
+// structuredData parses the STRUCTURED-DATA at the head of s, and returns the rest of s.
+// sd is nil if the STRUCTURED-DATA is the NILVALUE.
+func structuredData(s []byte) (sd map[string]map[string]string, rest []byte, err error) {
+	if len(s) > 0 && s[0] == '-' {
+		return nil, s[1:], nil
+	}
+	if len(s) == 0 || s[0] != '[' {
+		return nil, nil, errors.New("no SD-ELEMENT")
+	}
+	sd = make(map[string]map[string]string)
+	for len(s) > 0 && s[0] == '[' {
+		s = s[1:]
+		i := bytes.IndexAny(s, " ]")
+		if i <= 0 {
+			return nil, nil, errors.New("invalid SD-ID")
+		}
+		params := make(map[string]string)
+		sd[string(s[:i])] = params
+		s = s[i:]
+		for len(s) > 0 && s[0] == ' ' {
+			s = s[1:]
+			j := bytes.Index(s, []byte(`="`))
+			if j <= 0 {
+				return nil, nil, errors.New("invalid SD-PARAM")
+			}
+			name := string(s[:j])
+			value, n, err := sdParamValue(s[j+2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			params[name] = value
+			s = s[j+2+n:]
+		}
+		if len(s) == 0 || s[0] != ']' {
+			return nil, nil, errors.New("unterminated SD-ELEMENT")
+		}
+		s = s[1:]
+	}
+	return sd, s, nil
+}
+
+// sdParamValue returns the PARAM-VALUE terminated by '"', and the number of the bytes consumed including the '"'.
+// '"', '\' and ']' are escaped by '\'.
+func sdParamValue(s []byte) (string, int, error) {
+	var value []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return string(value), i + 1, nil
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+				i++
+				c = s[i]
+			}
+			value = append(value, c)
+		default:
+			value = append(value, c)
+		}
+	}
+	return "", 0, errors.New("unterminated PARAM-VALUE")
+}
+
+// setPriority sets the priority, the facility and the severity of the PRI
+func setPriority(fields Fields, pri []byte) error {
+	p, err := strconv.Atoi(string(pri))
+	if err != nil || p > 191 {
+		return fmt.Errorf("invalid PRI %s", pri)
+	}
+	fields["priority"] = p
+	fields["facility"] = p / 8
+	fields["severity"] = p % 8
+	return nil
+}
@@ -0,0 +1,102 @@
+package posfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writeFile writes b to f. it is replaced in tests to simulate the partial writes.
+var writeFile = func(f *os.File, b []byte) error {
+	_, err := f.Write(b)
+	return err
+}
+
+// writeFileAtomic writes b to a temp file, and renames it to the named file after fsync.
+// So the named file always holds either the old or the new content even if the process crashes while writing.
+func writeFileAtomic(name string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), tempPattern(name))
+	if err != nil {
+		return err
+	}
+	errAndRemove := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := writeFile(tmp, b); err != nil {
+		return errAndRemove(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return errAndRemove(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errAndRemove(err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(filepath.Dir(name))
+	return nil
+}
+
+// staleTempFileAge is the age of the temp file considered as left by the crash.
+// The younger temp file may be being written by the concurrent writer.
+const staleTempFileAge = time.Minute
+
+// removeTempFiles removes the temp files left by the crash while writing
+func removeTempFiles(name string) {
+	dir := filepath.Dir(name)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	// the directory is listed instead of the glob, since the path may contain the glob metacharacters
+	prefix := tempPrefix(name)
+	for _, ent := range entries {
+		if ent.IsDir() || !isTempFile(ent.Name(), prefix) {
+			continue
+		}
+		info, err := ent.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempFileAge {
+			continue
+		}
+		os.Remove(filepath.Join(dir, ent.Name()))
+	}
+}
+
+// isTempFile reports whether the name is created by os.CreateTemp with the tempPattern,
+// which replaces "*" with the random digits
+func isTempFile(name, prefix string) bool {
+	digits, ok := strings.CutPrefix(name, prefix)
+	if !ok || digits == "" {
+		return false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func tempPattern(name string) string {
+	return tempPrefix(name) + "*"
+}
+
+func tempPrefix(name string) string {
+	return filepath.Base(name) + ".tmp"
+}
+
+// syncDir fsyncs the directory to persist the rename.
+// it is the best effort since some platforms (e.g. Windows) does not support fsync on the directory.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,81 @@
+package posfile
+
+import (
+	"time"
+
+	"github.com/kei2100/follow/stat"
+)
+
+// Flusher is implemented by the PositionFile that coalesces the writes
+type Flusher interface {
+	// Flush writes the pending update to the disk
+	Flush() error
+}
+
+// FlushCounter is implemented by the PositionFile that counts the writes to the disk
+type FlushCounter interface {
+	// FlushCount returns the number of the writes to the disk
+	FlushCount() int64
+}
+
+// FileNamer is implemented by the PositionFile written to the disk
+type FileNamer interface {
+	// FileName returns the name of the file written to the disk.
+	// The PositionFiles of the same Store return the same name
+	FileName() string
+}
+
+// batch decides when the pending updates are written according to the flush policy.
+// the owner of the batch must serialize the calls.
+type batch struct {
+	opt     option
+	pending int64
+	dirty   bool
+	timer   *time.Timer
+	// writes is the number of the writes to the disk
+	writes int64
+}
+
+// add records the update of the offset by incr bytes, and reports whether the update should be written now.
+// If the update should be written later, add schedules flushLater after the flushInterval.
+func (b *batch) add(incr int64, force bool, flushLater func()) bool {
+	if incr < 0 {
+		incr = -incr
+	}
+	b.pending += incr
+	b.dirty = true
+	if force || (b.opt.flushBytes <= 0 && b.opt.flushInterval <= 0) {
+		return true
+	}
+	if b.opt.flushBytes > 0 && b.pending >= b.opt.flushBytes {
+		return true
+	}
+	if b.opt.flushInterval > 0 && b.timer == nil {
+		b.timer = time.AfterFunc(b.opt.flushInterval, flushLater)
+	}
+	return false
+}
+
+// written resets the pending updates after they are written to the disk
+func (b *batch) written() {
+	b.writes++
+	b.flushed()
+}
+
+// flushed resets the pending updates
+func (b *batch) flushed() {
+	b.pending = 0
+	b.dirty = false
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
+
+// fileChanged reports whether the update switches the file, which is always written immediately
+func fileChanged(old, new *stat.FileStat) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return !stat.SameFile(old, new)
+}
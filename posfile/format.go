@@ -0,0 +1,140 @@
+package posfile
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kei2100/follow/stat"
+)
+
+// FormatVersion is the version of the JSON format of the PositionFile and the Store
+const FormatVersion = 1
+
+// CorruptedError is returned when the PositionFile or the Store cannot be decoded
+type CorruptedError struct {
+	Name string
+	Err  error
+}
+
+func (e *CorruptedError) Error() string {
+	return fmt.Sprintf("posfile: %s is corrupted: %v", e.Name, e.Err)
+}
+
+func (e *CorruptedError) Unwrap() error {
+	return e.Err
+}
+
+// jsonEntry is the JSON representation of the entry
+type jsonEntry struct {
+	Version int     `json:"version,omitempty"`
+	Path    string  `json:"path,omitempty"`
+	Device  *uint64 `json:"device,omitempty"`
+	Inode   *uint64 `json:"inode,omitempty"`
+	Offset  int64   `json:"offset"`
+	// Fingerprint is the Fingerprint of the followed file
+	Fingerprint *stat.Fingerprint `json:"fingerprint,omitempty"`
+	// Truncation is the record of the truncation of the followed file
+	Truncation *Truncation `json:"truncation,omitempty"`
+}
+
+// jsonStore is the JSON representation of the Store
+type jsonStore struct {
+	Version int         `json:"version"`
+	Entries []jsonEntry `json:"entries"`
+}
+
+func toJSONEntry(path string, ent entry) jsonEntry {
+	je := jsonEntry{Path: path, Offset: ent.Offset, Truncation: ent.Truncation}
+	if ent.FileStat != nil {
+		dev, ino := stat.ID(ent.FileStat)
+		je.Device, je.Inode = &dev, &ino
+		je.Fingerprint = ent.FileStat.Fingerprint
+	}
+	return je
+}
+
+func (je jsonEntry) entry() entry {
+	ent := entry{Offset: je.Offset, Truncation: je.Truncation}
+	if je.Device != nil && je.Inode != nil {
+		ent.FileStat = stat.FromID(*je.Device, *je.Inode)
+		ent.FileStat.Fingerprint = je.Fingerprint
+	}
+	return ent
+}
+
+// isJSON reports whether b seems to be encoded in JSON.
+// The legacy gob encoding never starts with '{'.
+func isJSON(b []byte) bool {
+	b = bytes.TrimLeft(b, " \t\r\n")
+	return len(b) > 0 && b[0] == '{'
+}
+
+func encodeEntry(path string, ent entry) ([]byte, error) {
+	je := toJSONEntry(path, ent)
+	je.Version = FormatVersion
+	b, err := json.Marshal(je)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// decodeEntry decodes b encoded in JSON or the legacy gob.
+// migrate reports whether b is encoded in the legacy gob.
+func decodeEntry(name string, b []byte) (path string, ent entry, migrate bool, err error) {
+	if !isJSON(b) {
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&ent); err != nil {
+			return "", entry{}, false, &CorruptedError{Name: name, Err: err}
+		}
+		return "", ent, true, nil
+	}
+	var je jsonEntry
+	if err := json.Unmarshal(b, &je); err != nil {
+		return "", entry{}, false, &CorruptedError{Name: name, Err: err}
+	}
+	if je.Version != FormatVersion {
+		return "", entry{}, false, &CorruptedError{Name: name, Err: fmt.Errorf("unsupported version %d", je.Version)}
+	}
+	return je.Path, je.entry(), false, nil
+}
+
+func encodeStore(ents map[string]entry, keys []string) ([]byte, error) {
+	js := jsonStore{Version: FormatVersion, Entries: make([]jsonEntry, 0, len(keys))}
+	for _, k := range keys {
+		js.Entries = append(js.Entries, toJSONEntry(k, ents[k]))
+	}
+	b, err := json.MarshalIndent(js, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// decodeStore decodes b encoded in JSON or the legacy gob.
+// migrate reports whether b is encoded in the legacy gob.
+func decodeStore(name string, b []byte) (ents map[string]entry, migrate bool, err error) {
+	ents = make(map[string]entry)
+	if !isJSON(b) {
+		var ses []storeEntry
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&ses); err != nil {
+			return nil, false, &CorruptedError{Name: name, Err: err}
+		}
+		for _, se := range ses {
+			ents[se.Path] = entry{FileStat: se.FileStat, Offset: se.Offset}
+		}
+		return ents, true, nil
+	}
+	var js jsonStore
+	if err := json.Unmarshal(b, &js); err != nil {
+		return nil, false, &CorruptedError{Name: name, Err: err}
+	}
+	if js.Version != FormatVersion {
+		return nil, false, &CorruptedError{Name: name, Err: fmt.Errorf("unsupported version %d", js.Version)}
+	}
+	for _, je := range js.Entries {
+		ents[je.Path] = je.entry()
+	}
+	return ents, false, nil
+}
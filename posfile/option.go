@@ -0,0 +1,43 @@
+package posfile
+
+import "time"
+
+type option struct {
+	flushBytes    int64
+	flushInterval time.Duration
+}
+
+// OptionFunc let you change PositionFile and Store behavior.
+type OptionFunc func(o *option)
+
+// Default values
+const (
+	DefaultFlushBytes    = int64(0)
+	DefaultFlushInterval = time.Duration(0)
+)
+
+func (o *option) apply(opts ...OptionFunc) {
+	o.flushBytes = DefaultFlushBytes
+	o.flushInterval = DefaultFlushInterval
+	for _, fn := range opts {
+		fn(o)
+	}
+}
+
+// WithFlushBytes let you change flushBytes.
+// The offset is written to the disk when it advances flushBytes or more since the last write.
+// If both flushBytes and flushInterval are zero, the offset is written on every update
+func WithFlushBytes(v int64) OptionFunc {
+	return func(o *option) {
+		o.flushBytes = v
+	}
+}
+
+// WithFlushInterval let you change flushInterval.
+// The offset is written to the disk at least flushInterval after it advances.
+// If both flushBytes and flushInterval are zero, the offset is written on every update
+func WithFlushInterval(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.flushInterval = v
+	}
+}
@@ -1,8 +1,10 @@
 package posfile
 
 import (
-	"encoding/gob"
+	"io"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/kei2100/follow/stat"
 )
@@ -25,78 +27,235 @@ type PositionFile interface {
 	SetFileStat(fileStat *stat.FileStat) error
 }
 
+// PathRecorder is implemented by the PositionFile that records the path of the followed file
+type PathRecorder interface {
+	// SetPath set the path of the followed file
+	SetPath(path string)
+}
+
+// TruncationRecorder is implemented by the PositionFile that records the truncation of the followed file
+type TruncationRecorder interface {
+	// RecordTruncation records the truncation, and the number of bytes that may have been lost by the truncation
+	RecordTruncation(lostBytes int64) error
+	// Truncation returns the record of the truncation. nil if no truncation recorded
+	Truncation() *Truncation
+}
+
+// Truncation is the record of the truncation of the followed file
+type Truncation struct {
+	// Time is the time the last truncation detected
+	Time time.Time `json:"time"`
+	// Count is the number of the truncations detected
+	Count int `json:"count"`
+	// LostBytes is the number of bytes that may have been lost by the last truncation
+	LostBytes int64 `json:"lostBytes"`
+}
+
+func (t *Truncation) record(lostBytes int64) *Truncation {
+	next := Truncation{Time: time.Now(), Count: 1, LostBytes: lostBytes}
+	if t != nil {
+		next.Count = t.Count + 1
+	}
+	return &next
+}
+
 type entry struct {
-	FileStat *stat.FileStat
-	Offset   int64
+	FileStat   *stat.FileStat
+	Offset     int64
+	Truncation *Truncation
 }
 
-// Open opens named PositionFile
-func Open(name string) (PositionFile, error) {
-	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_SYNC, 0600)
+// Open opens named PositionFile.
+// The PositionFile is encoded in JSON. The PositionFile encoded in the legacy gob is migrated to JSON.
+// If the PositionFile cannot be decoded, Open returns *CorruptedError.
+// The PositionFile is updated by writing a temp file and renaming it, so that the update is atomic.
+// The updates of the offset are written according to the flush policy specified by opts,
+// and the update of the fileStat and Close always write the pending update.
+func Open(name string, opts ...OptionFunc) (PositionFile, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
 	if err != nil {
 		return nil, err
 	}
-	fi, err := f.Stat()
+	b, err := io.ReadAll(f)
+	if cErr := f.Close(); cErr != nil && err == nil {
+		err = cErr
+	}
 	if err != nil {
 		return nil, err
 	}
-	var ent entry
-	if fi.Size() == 0 {
-		return &positionFile{f: f, entry: ent}, nil
+	removeTempFiles(name)
+	if len(b) == 0 {
+		return &positionFile{name: name, batch: batch{opt: opt}}, nil
 	}
-	dec := gob.NewDecoder(f)
-	if err := dec.Decode(&ent); err != nil {
+	path, ent, migrate, err := decodeEntry(name, b)
+	if err != nil {
 		return nil, err
 	}
-	return &positionFile{f: f, entry: ent}, nil
+	pf := &positionFile{name: name, path: path, entry: ent, batch: batch{opt: opt}}
+	if migrate {
+		if err := pf.Flush(); err != nil {
+			return nil, err
+		}
+	}
+	return pf, nil
 }
 
 type positionFile struct {
-	f *os.File
+	name   string
+	path   string
+	closed bool
+	// flushErr is the error occurred while writing the pending update asynchronously
+	flushErr error
+	batch    batch
+	mu       sync.Mutex
 	entry
 }
 
 func (pf *positionFile) Close() error {
-	return pf.f.Close()
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	if pf.closed {
+		return nil
+	}
+	var err error
+	if pf.batch.dirty {
+		err = pf.flush()
+	}
+	pf.batch.flushed()
+	pf.closed = true
+	return err
 }
 
 func (pf *positionFile) FileStat() *stat.FileStat {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
 	return pf.entry.FileStat
 }
 
 func (pf *positionFile) Offset() int64 {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
 	return pf.entry.Offset
 }
 
 func (pf *positionFile) IncreaseOffset(incr int) error {
-	return pf.Set(pf.FileStat(), pf.Offset()+int64(incr))
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.set(pf.entry.FileStat, pf.entry.Offset+int64(incr))
 }
 
 func (pf *positionFile) Set(fileStat *stat.FileStat, offset int64) error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.set(fileStat, offset)
+}
+
+func (pf *positionFile) SetOffset(offset int64) error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.set(pf.entry.FileStat, offset)
+}
+
+func (pf *positionFile) SetFileStat(fileStat *stat.FileStat) error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.set(fileStat, pf.entry.Offset)
+}
+
+func (pf *positionFile) SetPath(path string) {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	pf.path = path
+}
+
+func (pf *positionFile) RecordTruncation(lostBytes int64) error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if pf.closed {
+		return os.ErrClosed
+	}
+	pf.entry.Truncation = pf.entry.Truncation.record(lostBytes)
+	return pf.flush()
+}
+
+func (pf *positionFile) Truncation() *Truncation {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.entry.Truncation
+}
+
+func (pf *positionFile) Flush() error {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	if pf.closed {
+		return os.ErrClosed
+	}
+	return pf.flush()
+}
+
+func (pf *positionFile) FileName() string {
+	return pf.name
+}
+
+func (pf *positionFile) FlushCount() int64 {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+	return pf.batch.writes
+}
+
+// set updates the entry. pf.mu must be held.
+func (pf *positionFile) set(fileStat *stat.FileStat, offset int64) error {
+	if pf.closed {
+		return os.ErrClosed
+	}
+	force := fileChanged(pf.entry.FileStat, fileStat)
+	incr := offset - pf.entry.Offset
 	pf.entry.FileStat = fileStat
 	pf.entry.Offset = offset
+	// the error of the asynchronous flush is reported after the update is applied
+	flushErr := pf.flushErr
+	pf.flushErr = nil
+	if !pf.batch.add(incr, force, pf.flushLater) {
+		return flushErr
+	}
+	// if the write fails, the update is kept dirty in memory and written by the next flush,
+	// so that the following IncreaseOffset is not based on the stale offset
+	return pf.flush()
+}
 
-	if _, err := pf.f.Seek(0, 0); err != nil {
+// flush writes the entry. pf.mu must be held.
+func (pf *positionFile) flush() error {
+	b, err := encodeEntry(pf.path, pf.entry)
+	if err != nil {
 		return err
 	}
-	enc := gob.NewEncoder(pf.f)
-	if err := enc.Encode(&pf.entry); err != nil {
+	if err := writeFileAtomic(pf.name, b); err != nil {
 		return err
 	}
+	pf.batch.written()
 	return nil
 }
 
-func (pf *positionFile) SetOffset(offset int64) error {
-	return pf.Set(pf.FileStat(), offset)
-}
+func (pf *positionFile) flushLater() {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
 
-func (pf *positionFile) SetFileStat(fileStat *stat.FileStat) error {
-	return pf.Set(fileStat, pf.Offset())
+	if pf.closed || !pf.batch.dirty {
+		return
+	}
+	if err := pf.flush(); err != nil {
+		pf.flushErr = err
+		pf.batch.timer = nil
+	}
 }
 
 // InMemory creates a inMemory PositionFile
 func InMemory(fileStat *stat.FileStat, offset int64) PositionFile {
-	return &inMemory{entry{FileStat: fileStat, Offset: offset}}
+	return &inMemory{entry: entry{FileStat: fileStat, Offset: offset}}
 }
 
 type inMemory struct {
@@ -132,3 +291,12 @@ func (pf *inMemory) SetOffset(offset int64) error {
 func (pf *inMemory) SetFileStat(fileStat *stat.FileStat) error {
 	return pf.Set(fileStat, pf.Offset())
 }
+
+func (pf *inMemory) RecordTruncation(lostBytes int64) error {
+	pf.entry.Truncation = pf.entry.Truncation.record(lostBytes)
+	return nil
+}
+
+func (pf *inMemory) Truncation() *Truncation {
+	return pf.entry.Truncation
+}
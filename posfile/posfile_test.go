@@ -1,8 +1,15 @@
 package posfile
 
 import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kei2100/follow/internal/testutil"
 
@@ -54,3 +61,403 @@ func TestOpenUpdate(t *testing.T) {
 		t.Fatalf("failed to close: %+v", err)
 	}
 }
+
+func TestOpenMigrateGob(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+
+	pfpath := filepath.Join(td.Path, "posfile")
+	f, err := os.Create(pfpath)
+	if err != nil {
+		t.Fatalf("failed to create posfile: %+v", err)
+	}
+	if err := gob.NewEncoder(f).Encode(&entry{FileStat: fileStat, Offset: 3}); err != nil {
+		t.Fatalf("failed to encode: %+v", err)
+	}
+	f.Close()
+
+	pf, err := Open(pfpath)
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	defer pf.Close()
+
+	if !stat.SameFile(pf.FileStat(), fileStat) {
+		t.Errorf("not same fileStat\ngot: \n%+v\nwant: \n%+v", pf.FileStat(), fileStat)
+	}
+	if g, w := pf.Offset(), int64(3); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+
+	b, err := os.ReadFile(pfpath)
+	if err != nil {
+		t.Fatalf("failed to read posfile: %+v", err)
+	}
+	var je jsonEntry
+	if err := json.Unmarshal(b, &je); err != nil {
+		t.Fatalf("posfile is not migrated to JSON: %+v\n%s", err, b)
+	}
+	if g, w := je.Version, FormatVersion; g != w {
+		t.Errorf("version got %v, want %v", g, w)
+	}
+	if g, w := je.Offset, int64(3); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+}
+
+func TestOpenCorrupted(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "truncated JSON", content: `{"version":1,"device":1,"ino`},
+		{name: "unsupported version", content: `{"version":999,"offset":1}`},
+		{name: "not gob", content: "\x00\x01\x02"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pfpath := filepath.Join(td.Path, fmt.Sprintf("posfile%d", i))
+			if err := os.WriteFile(pfpath, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("failed to write posfile: %+v", err)
+			}
+			_, err := Open(pfpath)
+			var cErr *CorruptedError
+			if !errors.As(err, &cErr) {
+				t.Errorf("err got %v, want *CorruptedError", err)
+			}
+		})
+	}
+}
+
+func TestSetPartialWrite(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+
+	pfpath := filepath.Join(td.Path, "posfile")
+	pf, err := Open(pfpath)
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	if err := pf.Set(fileStat, 1234567890); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+	if err := pf.Set(fileStat, 5); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+
+	// simulate the crash while writing
+	origWriteFile := writeFile
+	defer func() { writeFile = origWriteFile }()
+	writeFile = func(f *os.File, b []byte) error {
+		f.Write(b[:len(b)/2])
+		return errors.New("crashed")
+	}
+	if err := pf.Set(fileStat, 6); err == nil {
+		t.Errorf("want error on the partial write")
+	}
+	// the failed update is kept in memory, and written by the next flush
+	if g, w := pf.Offset(), int64(6); g != w {
+		t.Errorf("offset got %v, want %v after the failed set", g, w)
+	}
+	writeFile = origWriteFile
+	// the process crashed without closing pf
+
+	// temp file left by the crash
+	mustWriteStaleTempFile(t, pfpath+".tmp123")
+
+	pf2, err := Open(pfpath)
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	defer pf2.Close()
+
+	if !stat.SameFile(pf2.FileStat(), fileStat) {
+		t.Errorf("not same fileStat\ngot: \n%+v\nwant: \n%+v", pf2.FileStat(), fileStat)
+	}
+	if g, w := pf2.Offset(), int64(5); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+	entries, _ := filepath.Glob(filepath.Join(td.Path, "posfile.tmp*"))
+	if len(entries) > 0 {
+		t.Errorf("temp files remain %v", entries)
+	}
+}
+
+func TestIncreaseOffsetAfterFailedWrite(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+
+	pfpath := filepath.Join(td.Path, "posfile")
+	pf, err := Open(pfpath)
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	defer pf.Close()
+	if err := pf.Set(fileStat, 10); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+
+	origWriteFile := writeFile
+	defer func() { writeFile = origWriteFile }()
+	writeFile = func(f *os.File, b []byte) error {
+		return errors.New("no space left on device")
+	}
+	if err := pf.IncreaseOffset(5); err == nil {
+		t.Errorf("want error on the failed write")
+	}
+	writeFile = origWriteFile
+	if err := pf.IncreaseOffset(5); err != nil {
+		t.Fatalf("failed to increase offset: %+v", err)
+	}
+	if g, w := pf.Offset(), int64(20); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+	wantOffsetOnDisk(t, pfpath, 20)
+}
+
+func TestSetAfterFailedFlushLater(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+
+	var failing atomic.Bool
+	origWriteFile := writeFile
+	defer func() { writeFile = origWriteFile }()
+	writeFile = func(f *os.File, b []byte) error {
+		if failing.Load() {
+			return errors.New("no space left on device")
+		}
+		return origWriteFile(f, b)
+	}
+
+	pfpath := filepath.Join(td.Path, "posfile")
+	pf, err := Open(pfpath, WithFlushInterval(10*time.Millisecond), WithFlushBytes(1<<30))
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	if err := pf.Set(fileStat, 10); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+
+	failing.Store(true)
+	if err := pf.IncreaseOffset(5); err != nil {
+		t.Fatalf("failed to increase offset: %+v", err)
+	}
+	// wait for the asynchronous flush to fail
+	time.Sleep(100 * time.Millisecond)
+	failing.Store(false)
+
+	// the earlier error is reported, but the update is applied
+	if err := pf.IncreaseOffset(5); err == nil {
+		t.Errorf("want the error of the asynchronous flush")
+	}
+	if g, w := pf.Offset(), int64(20); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+	if err := pf.Close(); err != nil {
+		t.Fatalf("failed to close: %+v", err)
+	}
+	wantOffsetOnDisk(t, pfpath, 20)
+}
+
+func TestSetClosed(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+
+	pf, err := Open(filepath.Join(td.Path, "posfile"))
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	if err := pf.Set(fileStat, 5); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+	pf.Close()
+	if err := pf.Set(fileStat, 6); err == nil {
+		t.Errorf("want error after closed")
+	}
+	if g, w := pf.Offset(), int64(5); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+}
+
+func TestRemoveTempFilesInGlobMetaDir(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	dir := filepath.Join(td.Path, "a[b]*?")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Skipf("failed to create the directory: %v", err)
+	}
+	pfpath := filepath.Join(dir, "posfile")
+	mustWriteStaleTempFile(t, pfpath+".tmp123")
+	pf, err := Open(pfpath)
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	defer pf.Close()
+
+	if _, err := os.Stat(pfpath + ".tmp123"); !os.IsNotExist(err) {
+		t.Errorf("temp file remains: %v", err)
+	}
+}
+
+func TestRemoveTempFilesOnlyStale(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	pfpath := filepath.Join(td.Path, "state")
+	mustWriteStaleTempFile(t, pfpath+".tmp123")
+	// the file of the user matching the prefix
+	mustWriteStaleTempFile(t, pfpath+".tmpl")
+	// the temp file being written by the concurrent writer
+	if err := os.WriteFile(pfpath+".tmp456", []byte(`{"version":1,"off`), 0600); err != nil {
+		t.Fatalf("failed to write temp file: %+v", err)
+	}
+	pf, err := Open(pfpath)
+	if err != nil {
+		t.Fatalf("failed to open posfile: %+v", err)
+	}
+	defer pf.Close()
+
+	if _, err := os.Stat(pfpath + ".tmp123"); !os.IsNotExist(err) {
+		t.Errorf("stale temp file remains: %v", err)
+	}
+	for _, name := range []string{pfpath + ".tmpl", pfpath + ".tmp456"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s removed: %v", name, err)
+		}
+	}
+}
+
+func TestFlushPolicy(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+	file2, fileStat2 := td.CreateFile("bar.log")
+	defer file2.Close()
+
+	t.Run("FlushBytes", func(t *testing.T) {
+		pfpath := filepath.Join(td.Path, "posfile-bytes")
+		pf, err := Open(pfpath, WithFlushBytes(10))
+		if err != nil {
+			t.Fatalf("failed to open posfile: %+v", err)
+		}
+		pf.Set(fileStat, 0)
+		wantOffsetOnDisk(t, pfpath, 0)
+
+		pf.IncreaseOffset(3)
+		wantOffsetOnDisk(t, pfpath, 0)
+		pf.IncreaseOffset(7)
+		wantOffsetOnDisk(t, pfpath, 10)
+
+		// switching the file is written immediately
+		pf.IncreaseOffset(1)
+		pf.Set(fileStat2, 0)
+		wantOffsetOnDisk(t, pfpath, 0)
+
+		pf.IncreaseOffset(5)
+		wantOffsetOnDisk(t, pfpath, 0)
+		if err := pf.Close(); err != nil {
+			t.Fatalf("failed to close: %+v", err)
+		}
+		wantOffsetOnDisk(t, pfpath, 5)
+	})
+
+	t.Run("FlushInterval", func(t *testing.T) {
+		pfpath := filepath.Join(td.Path, "posfile-interval")
+		pf, err := Open(pfpath, WithFlushInterval(50*time.Millisecond))
+		if err != nil {
+			t.Fatalf("failed to open posfile: %+v", err)
+		}
+		defer pf.Close()
+		pf.Set(fileStat, 0)
+
+		pf.IncreaseOffset(3)
+		wantOffsetOnDisk(t, pfpath, 0)
+		time.Sleep(200 * time.Millisecond)
+		wantOffsetOnDisk(t, pfpath, 3)
+	})
+}
+
+// mustWriteStaleTempFile writes the temp file left by the crash long ago
+func mustWriteStaleTempFile(t *testing.T, name string) {
+	t.Helper()
+
+	if err := os.WriteFile(name, []byte(`{"version":1,"off`), 0600); err != nil {
+		t.Fatalf("failed to write temp file: %+v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(name, old, old); err != nil {
+		t.Fatalf("failed to change the time of the temp file: %+v", err)
+	}
+}
+
+func wantOffsetOnDisk(t *testing.T, name string, want int64) {
+	t.Helper()
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("failed to read posfile: %+v", err)
+	}
+	var je jsonEntry
+	if err := json.Unmarshal(b, &je); err != nil {
+		t.Fatalf("failed to decode posfile: %+v", err)
+	}
+	if g, w := je.Offset, want; g != w {
+		t.Errorf("offset on disk got %v, want %v", g, w)
+	}
+}
+
+func BenchmarkIncreaseOffset(b *testing.B) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo.log")
+	defer file.Close()
+
+	benchmarks := []struct {
+		name string
+		opts []OptionFunc
+	}{
+		{name: "EveryUpdate"},
+		{name: "FlushBytes64KiB", opts: []OptionFunc{WithFlushBytes(64 * 1024)}},
+		{name: "FlushInterval1s", opts: []OptionFunc{WithFlushInterval(time.Second)}},
+	}
+	for i, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			pf, err := Open(filepath.Join(td.Path, fmt.Sprintf("posfile%d", i)), bm.opts...)
+			if err != nil {
+				b.Fatalf("failed to open posfile: %+v", err)
+			}
+			defer pf.Close()
+			pf.Set(fileStat, 0)
+
+			b.SetBytes(512)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if err := pf.IncreaseOffset(512); err != nil {
+					b.Fatalf("failed to increase offset: %+v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package posfile
+
+import (
+	"errors"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/kei2100/follow/stat"
+)
+
+// ErrStoreClosed is returned when the Store is already closed
+var ErrStoreClosed = errors.New("posfile: store closed")
+
+// Store persists multiple position entries keyed by the file path in a single file
+type Store struct {
+	name    string
+	entries map[string]entry
+	closed  bool
+	// flushErr is the error occurred while writing the pending updates asynchronously
+	flushErr error
+	batch    batch
+	mu       sync.Mutex
+}
+
+// storeEntry is the legacy gob representation of the Store entry
+type storeEntry struct {
+	Path     string
+	FileStat *stat.FileStat
+	Offset   int64
+}
+
+// OpenStore opens named Store.
+// The Store is encoded in JSON. The Store encoded in the legacy gob is migrated to JSON.
+// If the Store cannot be decoded, OpenStore returns *CorruptedError.
+// The updates of the offsets are written according to the flush policy specified by opts,
+// and the update of the fileStat and Close always write the pending updates.
+func OpenStore(name string, opts ...OptionFunc) (*Store, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	s := &Store{name: name, entries: make(map[string]entry), batch: batch{opt: opt}}
+	removeTempFiles(name)
+	b, err := os.ReadFile(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if len(b) == 0 {
+		return s, nil
+	}
+	ents, migrate, err := decodeStore(name, b)
+	if err != nil {
+		return nil, err
+	}
+	s.entries = ents
+	if migrate {
+		if err := s.persist(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// PositionFile returns the PositionFile view of the entry for the key.
+// Closing the view does not close the Store.
+func (s *Store) PositionFile(key string) (PositionFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+	return &storeView{s: s, key: key}, nil
+}
+
+// Keys returns the keys of the entries
+func (s *Store) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.entries))
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Remove removes the entry for the key
+func (s *Store) Remove(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok {
+		return nil
+	}
+	delete(s.entries, key)
+	return s.persist()
+}
+
+// GC removes the entries for the files that no longer exist, and returns the removed keys
+func (s *Store) GC() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var removed []string
+	for k := range s.entries {
+		_, err := os.Stat(k)
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		delete(s.entries, k)
+		removed = append(removed, k)
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	sort.Strings(removed)
+	return removed, s.persist()
+}
+
+// Flush writes the pending updates to the disk
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStoreClosed
+	}
+	return s.persist()
+}
+
+// Close closes the Store
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	var err error
+	if s.batch.dirty {
+		err = s.persist()
+	}
+	s.batch.flushed()
+	s.closed = true
+	return err
+}
+
+// FlushCount returns the number of the writes of the Store to the disk
+func (s *Store) FlushCount() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.batch.writes
+}
+
+func (s *Store) get(key string) entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[key]
+}
+
+func (s *Store) set(key string, fileStat *stat.FileStat, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.setLocked(key, fileStat, offset)
+}
+
+// increase increases the offset of the entry for the key. the read and the update are done under s.mu
+func (s *Store) increase(key string, incr int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ent := s.entries[key]
+	return s.setLocked(key, ent.FileStat, ent.Offset+incr)
+}
+
+// setLocked updates the entry for the key. s.mu must be held.
+func (s *Store) setLocked(key string, fileStat *stat.FileStat, offset int64) error {
+	if s.closed {
+		return ErrStoreClosed
+	}
+	ent := s.entries[key]
+	force := fileChanged(ent.FileStat, fileStat)
+	incr := offset - ent.Offset
+	ent.FileStat, ent.Offset = fileStat, offset
+	s.entries[key] = ent
+	// the error of the asynchronous flush is reported after the update is applied
+	flushErr := s.flushErr
+	s.flushErr = nil
+	if !s.batch.add(incr, force, s.flushLater) {
+		return flushErr
+	}
+	// if the write fails, the update is kept dirty in memory and written by the next persist,
+	// so that the following increase is not based on the stale offset
+	return s.persist()
+}
+
+func (s *Store) recordTruncation(key string, lostBytes int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStoreClosed
+	}
+	ent := s.entries[key]
+	ent.Truncation = ent.Truncation.record(lostBytes)
+	s.entries[key] = ent
+	return s.persist()
+}
+
+func (s *Store) flushLater() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || !s.batch.dirty {
+		return
+	}
+	if err := s.persist(); err != nil {
+		s.flushErr = err
+		s.batch.timer = nil
+	}
+}
+
+// persist writes all entries to the file. s.mu must be held.
+func (s *Store) persist() error {
+	keys := make([]string, 0, len(s.entries))
+	for k := range s.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b, err := encodeStore(s.entries, keys)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.name, b); err != nil {
+		return err
+	}
+	s.batch.written()
+	return nil
+}
+
+type storeView struct {
+	s   *Store
+	key string
+}
+
+func (pf *storeView) Close() error {
+	return nil
+}
+
+func (pf *storeView) FileStat() *stat.FileStat {
+	return pf.s.get(pf.key).FileStat
+}
+
+func (pf *storeView) Offset() int64 {
+	return pf.s.get(pf.key).Offset
+}
+
+func (pf *storeView) IncreaseOffset(incr int) error {
+	return pf.s.increase(pf.key, int64(incr))
+}
+
+func (pf *storeView) Set(fileStat *stat.FileStat, offset int64) error {
+	return pf.s.set(pf.key, fileStat, offset)
+}
+
+func (pf *storeView) SetOffset(offset int64) error {
+	return pf.Set(pf.FileStat(), offset)
+}
+
+func (pf *storeView) SetFileStat(fileStat *stat.FileStat) error {
+	return pf.Set(fileStat, pf.Offset())
+}
+
+func (pf *storeView) RecordTruncation(lostBytes int64) error {
+	return pf.s.recordTruncation(pf.key, lostBytes)
+}
+
+func (pf *storeView) Truncation() *Truncation {
+	return pf.s.get(pf.key).Truncation
+}
+
+func (pf *storeView) Flush() error {
+	return pf.s.Flush()
+}
+
+// FileName returns the name of the Store, which is shared by all views
+func (pf *storeView) FileName() string {
+	return pf.s.name
+}
+
+// FlushCount returns the number of the writes of the Store, which are shared by all views
+func (pf *storeView) FlushCount() int64 {
+	return pf.s.FlushCount()
+}
@@ -0,0 +1,189 @@
+package posfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/stat"
+)
+
+func TestStore(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	foo, fooStat := td.CreateFile("foo.log")
+	foo.Close()
+	bar, barStat := td.CreateFile("bar.log")
+	bar.Close()
+
+	storePath := filepath.Join(td.Path, "store")
+	s, err := OpenStore(storePath)
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	fooPf, _ := s.PositionFile(foo.Name())
+	barPf, _ := s.PositionFile(bar.Name())
+	fooPf.Set(fooStat, 1)
+	fooPf.IncreaseOffset(2)
+	barPf.Set(barStat, 4)
+	if err := fooPf.Close(); err != nil {
+		t.Fatalf("failed to close the view: %+v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("failed to close: %+v", err)
+	}
+
+	s2, err := OpenStore(storePath)
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	defer s2.Close()
+
+	if g, w := s2.Keys(), []string{bar.Name(), foo.Name()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("keys got %v, want %v", g, w)
+	}
+	fooPf2, _ := s2.PositionFile(foo.Name())
+	if !stat.SameFile(fooPf2.FileStat(), fooStat) {
+		t.Errorf("not same fileStat\ngot: \n%+v\nwant: \n%+v", fooPf2.FileStat(), fooStat)
+	}
+	if g, w := fooPf2.Offset(), int64(3); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+
+	os.Remove(bar.Name())
+	removed, err := s2.GC()
+	if err != nil {
+		t.Fatalf("failed to gc: %+v", err)
+	}
+	if g, w := removed, []string{bar.Name()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("removed got %v, want %v", g, w)
+	}
+	if g, w := s2.Keys(), []string{foo.Name()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("keys got %v, want %v", g, w)
+	}
+}
+
+func TestStoreConcurrentIncreaseOffset(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	foo, fooStat := td.CreateFile("foo.log")
+	foo.Close()
+
+	s, err := OpenStore(filepath.Join(td.Path, "store"), WithFlushInterval(time.Hour), WithFlushBytes(1<<30))
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	defer s.Close()
+	pf, _ := s.PositionFile(foo.Name())
+	pf.Set(fooStat, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			view, _ := s.PositionFile(foo.Name())
+			for j := 0; j < 100; j++ {
+				view.IncreaseOffset(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if g, w := pf.Offset(), int64(1000); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+}
+
+func TestStoreIncreaseOffsetAfterFailedWrite(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	foo, fooStat := td.CreateFile("foo.log")
+	foo.Close()
+
+	name := filepath.Join(td.Path, "store")
+	s, err := OpenStore(name)
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	pf, _ := s.PositionFile(foo.Name())
+	if err := pf.Set(fooStat, 10); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+
+	origWriteFile := writeFile
+	defer func() { writeFile = origWriteFile }()
+	writeFile = func(f *os.File, b []byte) error {
+		return errors.New("no space left on device")
+	}
+	if err := pf.IncreaseOffset(5); err == nil {
+		t.Errorf("want error on the failed write")
+	}
+	writeFile = origWriteFile
+	if err := pf.IncreaseOffset(5); err != nil {
+		t.Fatalf("failed to increase offset: %+v", err)
+	}
+	s.Close()
+
+	s, err = OpenStore(name)
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	defer s.Close()
+	pf, _ = s.PositionFile(foo.Name())
+	if g, w := pf.Offset(), int64(20); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+}
+
+func TestStoreSetAfterFailedFlushLater(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	foo, fooStat := td.CreateFile("foo.log")
+	foo.Close()
+
+	var failing atomic.Bool
+	origWriteFile := writeFile
+	defer func() { writeFile = origWriteFile }()
+	writeFile = func(f *os.File, b []byte) error {
+		if failing.Load() {
+			return errors.New("no space left on device")
+		}
+		return origWriteFile(f, b)
+	}
+
+	s, err := OpenStore(filepath.Join(td.Path, "store"), WithFlushInterval(10*time.Millisecond), WithFlushBytes(1<<30))
+	if err != nil {
+		t.Fatalf("failed to open store: %+v", err)
+	}
+	defer s.Close()
+	pf, _ := s.PositionFile(foo.Name())
+	if err := pf.Set(fooStat, 10); err != nil {
+		t.Fatalf("failed to set: %+v", err)
+	}
+
+	failing.Store(true)
+	if err := pf.IncreaseOffset(5); err != nil {
+		t.Fatalf("failed to increase offset: %+v", err)
+	}
+	// wait for the asynchronous flush to fail
+	time.Sleep(100 * time.Millisecond)
+	failing.Store(false)
+
+	// the earlier error is reported, but the update is applied
+	if err := pf.IncreaseOffset(5); err == nil {
+		t.Errorf("want the error of the asynchronous flush")
+	}
+	if g, w := pf.Offset(), int64(20); g != w {
+		t.Errorf("offset got %v, want %v", g, w)
+	}
+}
@@ -1,16 +1,20 @@
 package follow
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/kei2100/follow/file"
-	"github.com/kei2100/follow/logger"
 	"github.com/kei2100/follow/stat"
 
 	"github.com/kei2100/follow/posfile"
@@ -20,29 +24,43 @@ import (
 func Open(name string, opts ...OptionFunc) (*Reader, error) {
 	opt := option{}
 	opt.apply(opts...)
+	log := opt.log()
 
 	var f *os.File
+	var dec io.ReadCloser
+	var rotated bool
 	var err error
 
 	errAndClose := func(err error) (*Reader, error) {
+		if dec != nil {
+			closeDecompressor(log, f, dec)
+		}
 		if f != nil {
 			if cErr := f.Close(); cErr != nil {
-				logger.Printf("follow: an error occurred while closing the file %s: %+v", name, cErr)
+				log.Error("follow: an error occurred while closing the file", pathAttr(name), errAttr(cErr))
 			}
 		}
 		if opt.positionFile != nil {
 			if cErr := opt.positionFile.Close(); cErr != nil {
-				logger.Printf("follow: an error occurred while closing the positionFile: %+v", cErr)
+				log.Error("follow: an error occurred while closing the positionFile", pathAttr(name), errAttr(cErr))
 			}
 		}
 		return nil, err
 	}
 
+	if opt.encoding != EncodingNone && opt.lineDelimiter >= utf8.RuneSelf {
+		return errAndClose(fmt.Errorf("follow: the lineDelimiter 0x%x is not ASCII and cannot be used with the encoding", opt.lineDelimiter))
+	}
+
+	if len(opt.rotatedFilePathPatterns) > 0 && opt.fingerprintSize <= 0 {
+		log.Warn("follow: the compressed rotated files are not found without the Fingerprint. see WithFingerprintSize", pathAttr(name))
+	}
+
 	f, err = file.Open(name)
 	if err != nil {
 		return errAndClose(err)
 	}
-	fileStat, err := stat.Stat(f)
+	fileStat, err := statFile(f, opt.fingerprintSize)
 	if err != nil {
 		return errAndClose(err)
 	}
@@ -55,52 +73,125 @@ func Open(name string, opts ...OptionFunc) (*Reader, error) {
 	if !opt.readFromHead {
 		initialOffset = fileInfo.Size()
 	}
+	// startStat and startOffset are the initial position applied only when the positionFile has no prior state
+	startStat, startOffset := fileStat, initialOffset
+	// startPosition computes the start options lazily, since they scan the file
+	startPosition := func() error {
+		if opt.startFromLastLines > 0 {
+			delim, err := sourceDelimiterOf(f, opt)
+			if err != nil {
+				return err
+			}
+			offset, err := offsetOfLastLines(f, fileInfo.Size(), opt.startFromLastLines, delim)
+			if err != nil {
+				return err
+			}
+			startOffset = offset
+		}
+		if opt.startFromTime.IsZero() {
+			return nil
+		}
+		st, offset, err := searchStartTime(f, fileInfo.Size(), name, opt)
+		if err != nil {
+			return err
+		}
+		if st != nil {
+			// the rotated file found later by the rotatedFilePathPatterns
+			startStat = st
+		}
+		startOffset = offset
+		return nil
+	}
 
 	positionFile := opt.positionFile
 	if positionFile == nil {
-		logger.Println("follow: positionFile not specified. use in-memory positionFile.")
-		positionFile = posfile.InMemory(fileStat, initialOffset)
+		log.Debug("follow: positionFile not specified. use in-memory positionFile", pathAttr(name))
+		if err := startPosition(); err != nil {
+			return errAndClose(err)
+		}
+		positionFile = posfile.InMemory(startStat, startOffset)
+	}
+	if pr, ok := positionFile.(posfile.PathRecorder); ok {
+		pr.SetPath(name)
 	}
 	if positionFile.FileStat() == nil {
-		if err := positionFile.Set(fileStat, initialOffset); err != nil {
+		if err := startPosition(); err != nil {
 			return errAndClose(err)
 		}
+		if err := positionFile.Set(startStat, startOffset); err != nil {
+			return errAndClose(err)
+		}
+	}
+	same, err := stat.SameFileContent(f, fileStat, positionFile.FileStat())
+	if err != nil {
+		return errAndClose(err)
 	}
-	if !stat.SameFile(fileStat, positionFile.FileStat()) {
-		logger.Printf("follow: file not found that matches fileStat of the positionFile %+v.", positionFile.FileStat())
-		sameFile, sameFileStat, sameFileInfo, err := findSameFile(opt.rotatedFilePathPatterns, positionFile.FileStat())
+	if !same {
+		log.Info("follow: the file does not match fileStat of the positionFile", pathAttr(name), inodeAttr(positionFile.FileStat()), offsetAttr(positionFile.Offset()))
+		found, err := findSameFile(log, opt.rotatedFilePathPatterns, positionFile.FileStat())
 		if err != nil {
 			if !os.IsNotExist(err) {
 				return errAndClose(err)
 			}
-			logger.Printf("follow: reset positionFile %+v.", positionFile.FileStat())
+			log.Warn("follow: rotated file not found that matches fileStat of the positionFile. reset the positionFile", pathAttr(name), inodeAttr(positionFile.FileStat()), offsetAttr(positionFile.Offset()))
+			prevStat, prevOffset := positionFile.FileStat(), positionFile.Offset()
 			if err := positionFile.Set(fileStat, initialOffset); err != nil {
 				return errAndClose(err)
 			}
+			emitEvent(opt.eventHandler, Event{Type: EventPositionReset, Path: name, FileStat: fileStat, Offset: initialOffset, PrevFileStat: prevStat, PrevOffset: prevOffset})
 		} else {
-			logger.Printf("follow: %s matches fileStat of the positionFile.", sameFile.Name())
-			f = sameFile
-			fileStat = sameFileStat
-			fileInfo = sameFileInfo
+			log.Info("follow: the rotated file matches fileStat of the positionFile", pathAttr(found.f.Name()), inodeAttr(positionFile.FileStat()), offsetAttr(positionFile.Offset()))
+			if cErr := f.Close(); cErr != nil {
+				log.Error("follow: an error occurred while closing the file", pathAttr(name), errAttr(cErr))
+			}
+			f = found.f
+			fileInfo = found.fileInfo
+			dec = found.dec
+			rotated = true
 		}
 	}
 
-	if fileInfo.Size() < positionFile.Offset() {
-		// consider file truncated
-		logger.Printf("follow: incorrect positionFile offset %d. file size %d. reset offset to %d.", positionFile.Offset(), fileInfo.Size(), fileInfo.Size())
-		if err := positionFile.SetOffset(fileInfo.Size()); err != nil {
-			return errAndClose(err)
+	resetOffset := func(size int64) error {
+		prevOffset := positionFile.Offset()
+		if err := positionFile.SetOffset(size); err != nil {
+			return err
 		}
+		emitEvent(opt.eventHandler, Event{Type: EventPositionReset, Path: f.Name(), FileStat: positionFile.FileStat(), Offset: size, PrevFileStat: positionFile.FileStat(), PrevOffset: prevOffset})
+		return nil
 	}
-	offset, err := f.Seek(positionFile.Offset(), 0)
-	if err != nil {
-		return errAndClose(err)
-	}
-	if offset != positionFile.Offset() {
-		return errAndClose(fmt.Errorf("follow: seems like seek failed. positionFile offset %d. file offset %d", positionFile.Offset(), offset))
+	if dec != nil {
+		// the size of the compressed file is not comparable with the offset.
+		// skip the decompressed bytes up to the offset instead of seeking.
+		skipped, err := io.CopyN(io.Discard, dec, positionFile.Offset())
+		if err != nil && err != io.EOF {
+			return errAndClose(err)
+		}
+		if skipped < positionFile.Offset() {
+			log.Warn("follow: incorrect positionFile offset. reset offset to the decompressed size", pathAttr(f.Name()), inodeAttr(positionFile.FileStat()), offsetAttr(positionFile.Offset()), slog.Int64("size", skipped))
+			if err := resetOffset(skipped); err != nil {
+				return errAndClose(err)
+			}
+		}
+	} else {
+		if fileInfo.Size() < positionFile.Offset() {
+			// consider file truncated
+			log.Warn("follow: incorrect positionFile offset. reset offset to the file size", pathAttr(f.Name()), inodeAttr(positionFile.FileStat()), offsetAttr(positionFile.Offset()), slog.Int64("size", fileInfo.Size()))
+			if err := resetOffset(fileInfo.Size()); err != nil {
+				return errAndClose(err)
+			}
+		}
+		offset, err := f.Seek(positionFile.Offset(), 0)
+		if err != nil {
+			return errAndClose(err)
+		}
+		if offset != positionFile.Offset() {
+			return errAndClose(fmt.Errorf("follow: seems like seek failed. positionFile offset %d. file offset %d", positionFile.Offset(), offset))
+		}
 	}
 
-	return newReader(f, name, positionFile, opt.optionFollowRotate), nil
+	r := newReader(f, dec, name, positionFile, opt)
+	emitEvent(opt.eventHandler, Event{Type: EventOpened, Path: f.Name(), FileStat: positionFile.FileStat(), Offset: positionFile.Offset(), Rotated: rotated})
+	return r, nil
 }
 
 const (
@@ -114,16 +205,19 @@ type Reader struct {
 	fu             *fileUnit
 	state          int32
 	followFilePath string
-	opt            optionFollowRotate
+	opt            option
 	closed         chan struct{}
 	rotated        chan struct{}
+	// wake is notified when the file is modified, if the notification is available
+	wake chan struct{}
 }
 
-func newReader(file *os.File, followFilePath string, positionFile posfile.PositionFile, opt optionFollowRotate) *Reader {
-	fu := newFileUnit(file, positionFile)
+func newReader(file *os.File, dec io.ReadCloser, followFilePath string, positionFile posfile.PositionFile, opt option) *Reader {
+	fu := newFileUnit(file, dec, positionFile, opt)
 	closed := make(chan struct{})
 	rotated := make(chan struct{})
-	watchRotate(closed, rotated, fu, followFilePath, opt)
+	wake := make(chan struct{}, 1)
+	watchRotate(closed, rotated, wake, fu, followFilePath, opt.optionFollowRotate)
 	return &Reader{
 		fu:             fu,
 		state:          sNormal,
@@ -131,72 +225,255 @@ func newReader(file *os.File, followFilePath string, positionFile posfile.Positi
 		opt:            opt,
 		closed:         closed,
 		rotated:        rotated,
+		wake:           wake,
 	}
 }
 
 // Read reads up to len(b) bytes from the File.
+// Read returns io.EOF when no new bytes are available, unless the blocking read is enabled by WithBlockingRead.
 func (r *Reader) Read(p []byte) (n int, err error) {
+	if r.opt.blockingRead {
+		return r.ReadContext(context.Background(), p)
+	}
+	n, _, err = r.read(p)
+	return n, err
+}
+
+// ReadContext reads up to len(p) bytes from the File.
+// Unlike Read, ReadContext blocks until new bytes are available, the rotated file is switched, or ctx is done.
+// ReadContext returns ctx.Err() if ctx is done, and io.EOF if the follow.Reader is closed while waiting.
+func (r *Reader) ReadContext(ctx context.Context, p []byte) (n int, err error) {
+	n, _, err = r.readContext(ctx, p)
+	return n, err
+}
+
+func (r *Reader) readContext(ctx context.Context, p []byte) (int, Position, error) {
+	if len(p) == 0 {
+		return 0, Position{}, nil
+	}
+	var tick *time.Ticker
+	for {
+		n, pos, err := r.read(p)
+		if n > 0 {
+			return n, pos, err
+		}
+		if err != nil && err != io.EOF {
+			select {
+			case <-r.closed:
+				// closed while reading
+				return 0, Position{}, io.EOF
+			default:
+				return 0, Position{}, err
+			}
+		}
+		if tick == nil {
+			tick = time.NewTicker(r.opt.readPollInterval)
+			defer tick.Stop()
+		}
+		select {
+		case <-ctx.Done():
+			return 0, Position{}, ctx.Err()
+		case <-r.closed:
+			return 0, Position{}, io.EOF
+		case <-r.rotated:
+			atomic.CompareAndSwapInt32(&r.state, sNormal, sReadRemaining)
+		case <-r.wake:
+		case <-tick.C:
+		}
+	}
+}
+
+// read reads up to len(p) bytes from the File, and returns the Position just after the read bytes.
+func (r *Reader) read(p []byte) (int, Position, error) {
+	n, pos, err := r.readState(p)
+	if err != nil && err != io.EOF && !r.closing(err) {
+		r.fu.emit(Event{Type: EventReadError, Path: r.fu.fileName(), FileStat: pos.FileStat, Offset: pos.Offset, Err: err})
+	}
+	return n, pos, err
+}
+
+// closing reports whether err is caused by Close called while reading
+func (r *Reader) closing(err error) bool {
+	select {
+	case <-r.closed:
+		return true
+	default:
+		return errors.Is(err, os.ErrClosed)
+	}
+}
+
+func (r *Reader) readState(p []byte) (int, Position, error) {
 	switch atomic.LoadInt32(&r.state) {
 	case sNormal:
 		select {
 		default:
-			return r.fu.readFile(p)
+			n, pos, err := r.fu.readFile(p)
+			if err != io.EOF {
+				return n, pos, err
+			}
+			truncated, tErr := r.fu.handleTruncate(r.opt.truncatePolicy)
+			if tErr != nil {
+				return n, pos, tErr
+			}
+			if truncated {
+				return r.fu.readFile(p)
+			}
+			return n, pos, err
 		case <-r.rotated:
 			atomic.StoreInt32(&r.state, sReadRemaining)
-			return r.Read(p)
+			return r.readState(p)
 		}
 
 	case sReadRemaining:
-		n, err := r.fu.readFile(p)
+		n, pos, err := r.fu.readFile(p)
 		if err == nil {
-			return n, nil
+			return n, pos, nil
 		}
 		if err != nil && err != io.EOF {
-			return n, err
+			return n, pos, err
 		}
 		// io.EOF (= finish read remaining bytes from rotated file)
 		// switch reading to the next file
 		if !atomic.CompareAndSwapInt32(&r.state, sReadRemaining, sRotating) {
 			// ensure that switching the file is performed by single goroutine
-			return 0, io.EOF
+			return 0, pos, io.EOF
 		}
-		next, err := file.Open(r.followFilePath)
+		next, dec, backlog, err := r.openNextFile()
 		if err != nil {
 			atomic.StoreInt32(&r.state, sReadRemaining)
-			logger.Printf("follow: failed to open the next file. wait for switching the file until next reading: %+v", err)
-			return 0, io.EOF
+			r.fu.log.Error("follow: failed to open the next file. wait for switching the file until next reading", pathAttr(r.followFilePath), errAttr(err))
+			r.fu.emit(Event{Type: EventReadError, Path: r.followFilePath, Err: err})
+			return 0, pos, io.EOF
+		}
+		prevPath := r.fu.fileName()
+		if err := r.fu.switchFile(next, dec); err != nil {
+			atomic.StoreInt32(&r.state, sReadRemaining)
+			r.fu.log.Error("follow: failed to switching the file. wait until next reading", pathAttr(next.Name()), errAttr(err))
+			r.fu.emit(Event{Type: EventReadError, Path: next.Name(), Err: err})
+			return 0, pos, io.EOF
 		}
-		if err := r.fu.switchFile(next); err != nil {
+		current := r.fu.readPosition()
+		r.fu.emit(Event{Type: EventSwitchedFile, Path: next.Name(), FileStat: current.FileStat, Offset: current.Offset, PrevPath: prevPath, PrevFileStat: pos.FileStat, PrevOffset: pos.Offset, Rotated: backlog})
+		if backlog {
+			// the rotated file is no longer written. read it to the end before the next file
 			atomic.StoreInt32(&r.state, sReadRemaining)
-			logger.Printf("follow: failed to switching the file. wait until next reading: %+v", err)
-			return 0, io.EOF
+			return r.readState(p)
 		}
-		watchRotate(r.closed, r.rotated, r.fu, r.followFilePath, r.opt)
+		watchRotate(r.closed, r.rotated, r.wake, r.fu, r.followFilePath, r.opt.optionFollowRotate)
 		atomic.StoreInt32(&r.state, sNormal)
-		return r.Read(p)
+		return r.readState(p)
 
 	case sRotating:
-		return 0, io.EOF
+		return 0, Position{}, io.EOF
 
 	default:
-		return 0, fmt.Errorf("follow: unexpected state %d", atomic.LoadInt32(&r.state))
+		return 0, Position{}, fmt.Errorf("follow: unexpected state %d", atomic.LoadInt32(&r.state))
+	}
+}
+
+// Position returns the Position just after the bytes read so far.
+// The returned Position can be passed to CommitUpTo.
+func (r *Reader) Position() Position {
+	return r.fu.readPosition()
+}
+
+// Commit commits the offset of the file currently being read to the positionFile.
+// Like CommitUpTo, Commit returns an error if offset is negative or older than the committed offset.
+// Commit is intended to be used with WithManualCommit.
+func (r *Reader) Commit(offset int64) error {
+	pos := r.fu.readPosition()
+	if offset > pos.Offset {
+		return fmt.Errorf("follow: commit offset %d exceeds the read offset %d", offset, pos.Offset)
+	}
+	return r.fu.commit(Position{FileStat: pos.FileStat, Offset: offset})
+}
+
+// CommitUpTo commits the Position returned by Position to the positionFile.
+// Unlike Commit, CommitUpTo can commit the Position of the file before the rotation.
+// CommitUpTo returns an error if pos is older than the committed Position,
+// unless the file is read again from the offset behind it by the truncation or Seek.
+// CommitUpTo is intended to be used with WithManualCommit.
+func (r *Reader) CommitUpTo(pos Position) error {
+	if pos.FileStat == nil {
+		return fmt.Errorf("follow: commit position has no FileStat")
 	}
+	return r.fu.commit(pos)
 }
 
 // Close closes the follow.Reader.
 func (r *Reader) Close() error {
 	close(r.closed)
-	return r.fu.close()
+	pos := r.fu.readPosition()
+	path := r.fu.fileName()
+	err := r.fu.close()
+	r.fu.emit(Event{Type: EventClosed, Path: path, FileStat: pos.FileStat, Offset: pos.Offset})
+	return err
+}
+
+// Position is a position in the followed files
+type Position struct {
+	// FileStat is the FileStat of the file
+	FileStat *stat.FileStat
+	// Offset is the offset in the file
+	Offset int64
 }
 
 type fileUnit struct {
-	f  *os.File
-	pf posfile.PositionFile
-	mu sync.Mutex
+	f *os.File
+	// dec is the decompressing reader of f if f is the compressed rotated file
+	dec io.ReadCloser
+	// conv converts the encoding of the bytes read from f. nil if the encoding is EncodingNone
+	conv *converter
+	pf   posfile.PositionFile
+	// readStat and readOffset hold the position of the bytes read from f.
+	// they are ahead of the positionFile if manualCommit is enabled.
+	readStat     *stat.FileStat
+	readOffset   int64
+	manualCommit bool
+	// uncommitted holds the FileStats of the generations read after the committed position in order.
+	// a generation starts by the rotation, the truncation or Seek, so the same file may appear more than once.
+	// it is used to reject the commit of the Position older than the committed one
+	uncommitted []*stat.FileStat
+	// fingerprintSize is the max number of bytes for the Fingerprint. zero means the Fingerprint is disabled
+	fingerprintSize int64
+	// observedSize is the largest size of f observed by watchRotate
+	observedSize int64
+	// lineDelimiter, bytesRead, linesRead, rotations, lastRead and lastGrowth are for the Stats
+	lineDelimiter byte
+	bytesRead     int64
+	linesRead     int64
+	rotations     int
+	lastRead      time.Time
+	lastGrowth    time.Time
+	eventHandler  EventHandler
+	log           *slog.Logger
+	mu            sync.Mutex
+}
+
+func newFileUnit(f *os.File, dec io.ReadCloser, pf posfile.PositionFile, opt option) *fileUnit {
+	var conv *converter
+	if opt.encoding != EncodingNone {
+		conv = newConverter(opt, pf.Offset())
+	}
+	return &fileUnit{
+		f:               f,
+		dec:             dec,
+		conv:            conv,
+		pf:              pf,
+		readStat:        pf.FileStat(),
+		readOffset:      pf.Offset(),
+		manualCommit:    opt.manualCommit,
+		fingerprintSize: opt.fingerprintSize,
+		lineDelimiter:   opt.lineDelimiter,
+		lastGrowth:      time.Now(),
+		eventHandler:    opt.eventHandler,
+		log:             opt.log(),
+	}
 }
 
-func newFileUnit(f *os.File, pf posfile.PositionFile) *fileUnit {
-	return &fileUnit{f: f, pf: pf}
+// emit emits the Event. fu.mu must not be held, because the handler may call the methods of the follow.Reader.
+func (fu *fileUnit) emit(ev Event) {
+	emitEvent(fu.eventHandler, ev)
 }
 
 func (fu *fileUnit) close() error {
@@ -204,8 +481,9 @@ func (fu *fileUnit) close() error {
 	defer fu.mu.Unlock()
 
 	if err := fu.pf.Close(); err != nil {
-		logger.Printf("follow: an error occurred while closing the positionFile: %+v", err)
+		fu.log.Error("follow: an error occurred while closing the positionFile", pathAttr(fu.f.Name()), errAttr(err))
 	}
+	fu.closeDecompressor()
 	return fu.f.Close()
 }
 
@@ -227,47 +505,333 @@ func (fu *fileUnit) positionFileInfo() (fileStat *stat.FileStat, offset int64) {
 	return fu.pf.FileStat(), fu.pf.Offset()
 }
 
-func (fu *fileUnit) readFile(p []byte) (int, error) {
+func (fu *fileUnit) readPosition() Position {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+	return Position{FileStat: fu.readStat, Offset: fu.readOffset}
+}
+
+func (fu *fileUnit) readFile(p []byte) (int, Position, error) {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	n, consumed, err := fu.readSource(p)
+	fu.readOffset += int64(consumed)
+	if consumed > 0 {
+		fu.bytesRead += int64(consumed)
+		fu.lastRead = time.Now()
+	}
+	if n > 0 {
+		fu.linesRead += int64(bytes.Count(p[:n], []byte{fu.lineDelimiter}))
+	}
+	if consumed == 0 && err != nil {
+		return n, Position{FileStat: fu.readStat, Offset: fu.readOffset}, err
+	}
+	updated, uErr := fu.updateFingerprint()
+	pos := Position{FileStat: fu.readStat, Offset: fu.readOffset}
+	if uErr != nil {
+		return n, pos, uErr
+	}
+	if fu.manualCommit {
+		return n, pos, err
+	}
+	if updated {
+		if err := fu.pf.Set(fu.readStat, fu.pf.Offset()+int64(consumed)); err != nil {
+			return n, pos, err
+		}
+		return n, pos, err
+	}
+	if err := fu.pf.IncreaseOffset(consumed); err != nil {
+		return n, pos, err
+	}
+	return n, pos, err
+}
+
+// readSource reads the bytes from f, or from the decompressing reader and the converter if any.
+// n is the number of the bytes in p, and consumed is the number of the bytes of the file. fu.mu must be held.
+func (fu *fileUnit) readSource(p []byte) (n, consumed int, err error) {
+	var src io.Reader = fu.f
+	if fu.dec != nil {
+		src = fu.dec
+	}
+	if fu.conv != nil {
+		return fu.conv.read(src, fu.head, p)
+	}
+	n, err = src.Read(p)
+	if fu.dec != nil && n > 0 && err == io.EOF {
+		// the decompressor may return io.EOF with the last bytes. return io.EOF on the next read
+		err = nil
+	}
+	return n, n, err
+}
+
+// head returns the bytes at the head of the file to detect the BOM. fu.mu must be held.
+func (fu *fileUnit) head() ([]byte, error) {
+	var r io.Reader = io.NewSectionReader(fu.f, 0, maxBOMLen)
+	if fu.dec != nil {
+		dec, err := openDecompressor(fu.f)
+		if err != nil {
+			return nil, err
+		}
+		defer closeDecompressor(fu.log, fu.f, dec)
+		r = dec
+	}
+	b := make([]byte, maxBOMLen)
+	n, err := io.ReadFull(r, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return b[:n], err
+}
+
+// updateFingerprint extends the Fingerprint of the readStat while the file is smaller than the fingerprintSize.
+// fu.mu must be held.
+func (fu *fileUnit) updateFingerprint() (bool, error) {
+	if fu.fingerprintSize <= 0 || fu.readStat == nil || fu.dec != nil {
+		return false, nil
+	}
+	if fp := fu.readStat.Fingerprint; fp != nil && (fp.Size >= fu.fingerprintSize || fp.Size >= fu.readOffset) {
+		return false, nil
+	}
+	fp, err := stat.ComputeFingerprint(fu.f, fu.fingerprintSize)
+	if err != nil {
+		return false, err
+	}
+	st := *fu.readStat
+	st.Fingerprint = fp
+	fu.readStat = &st
+	return true, nil
+}
+
+// handleTruncate detects the truncation of f, and applies the policy.
+// handleTruncate reports whether f is truncated.
+func (fu *fileUnit) handleTruncate(policy TruncatePolicy) (bool, error) {
+	truncated, ev, err := fu.truncate(policy)
+	if truncated {
+		fu.emit(ev)
+	}
+	return truncated, err
+}
+
+func (fu *fileUnit) truncate(policy TruncatePolicy) (bool, Event, error) {
 	fu.mu.Lock()
 	defer fu.mu.Unlock()
 
-	n, err := fu.f.Read(p)
+	if fu.dec != nil {
+		// the compressed rotated file is never truncated
+		return false, Event{}, nil
+	}
+	fi, err := fu.f.Stat()
 	if err != nil {
-		return n, err
+		return false, Event{}, err
 	}
-	if err := fu.pf.IncreaseOffset(n); err != nil {
-		return n, err
+	size := fi.Size()
+	if size >= fu.readOffset && size >= fu.observedSize {
+		return false, Event{}, nil
 	}
-	return n, nil
+
+	// the bytes written after the last read and before the truncation may have been lost
+	lostBytes := fu.observedSize - fu.readOffset
+	if lostBytes < 0 {
+		lostBytes = 0
+	}
+	var offset int64
+	if policy == TruncateReadFromEnd {
+		offset = size
+		lostBytes += size
+	}
+	fu.log.Warn("follow: the file truncated. reset offset", pathAttr(fu.f.Name()), inodeAttr(fu.readStat), offsetAttr(offset), slog.Int64("size", size), slog.Int64("read_offset", fu.readOffset), slog.Int64("lost_bytes", lostBytes))
+
+	if _, err := fu.f.Seek(offset, io.SeekStart); err != nil {
+		return false, Event{}, err
+	}
+	ev := Event{Type: EventTruncated, Path: fu.f.Name(), FileStat: fu.readStat, Offset: offset, PrevOffset: fu.readOffset, LostBytes: lostBytes}
+	fu.readOffset = offset
+	fu.resetConverter()
+	fu.observedSize = size
+	if !fu.manualCommit {
+		if err := fu.pf.SetOffset(offset); err != nil {
+			return false, Event{}, err
+		}
+	}
+	fu.startGeneration(fu.readStat)
+	if tr, ok := fu.pf.(posfile.TruncationRecorder); ok {
+		if err := tr.RecordTruncation(lostBytes); err != nil {
+			return false, Event{}, err
+		}
+	}
+	return true, ev, nil
+}
+
+// committedAll reports whether the positionFile is committed up to the bytes read from f
+func (fu *fileUnit) committedAll() bool {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	committed := fu.pf.FileStat()
+	if committed == nil || fu.readStat == nil {
+		return committed == fu.readStat
+	}
+	return len(fu.uncommitted) == 0 && stat.SameFile(committed, fu.readStat) && fu.pf.Offset() == fu.readOffset
 }
 
-func (fu *fileUnit) switchFile(next *os.File) error {
+// observeSize records the current size of f
+func (fu *fileUnit) observeSize() {
 	fu.mu.Lock()
 	defer fu.mu.Unlock()
 
-	st, err := stat.Stat(next)
+	if fu.dec != nil {
+		return
+	}
+	fi, err := fu.f.Stat()
 	if err != nil {
+		return
+	}
+	fu.observe(fi.Size())
+}
+
+// observe records size as the size of f. fu.mu must be held.
+func (fu *fileUnit) observe(size int64) {
+	if size > fu.observedSize {
+		fu.observedSize = size
+		fu.lastGrowth = time.Now()
+	}
+}
+
+func (fu *fileUnit) commit(pos Position) error {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	if pos.Offset < 0 {
+		return fmt.Errorf("follow: commit offset %d is negative", pos.Offset)
+	}
+	passed, ok := fu.commitGeneration(pos)
+	if !ok {
+		return fmt.Errorf("follow: commit position (offset %d) is older than the committed position (offset %d)", pos.Offset, fu.pf.Offset())
+	}
+	if err := fu.pf.Set(pos.FileStat, pos.Offset); err != nil {
 		return err
 	}
-	if err := fu.pf.Set(st, 0); err != nil {
+	fu.uncommitted = fu.uncommitted[passed:]
+	return nil
+}
+
+// commitGeneration finds the generation of pos, and returns the number of the uncommitted generations passed by pos.
+// commitGeneration reports false if pos is older than the committed position. fu.mu must be held.
+func (fu *fileUnit) commitGeneration(pos Position) (int, bool) {
+	committed := fu.pf.FileStat()
+	if committed == nil || stat.SameFile(committed, pos.FileStat) && pos.Offset >= fu.pf.Offset() {
+		return 0, true
+	}
+	for i, st := range fu.uncommitted {
+		if stat.SameFile(st, pos.FileStat) {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// startGeneration records that the reading of st started from the offset that may be behind the committed one,
+// by the rotation, the truncation or Seek. fu.mu must be held.
+func (fu *fileUnit) startGeneration(st *stat.FileStat) {
+	if !fu.manualCommit {
+		// the positionFile is already updated
+		fu.uncommitted = nil
+		return
+	}
+	fu.uncommitted = append(fu.uncommitted, st)
+}
+
+// switchFile switches reading to next.
+// dec is the decompressing reader of next if next is the compressed rotated file.
+func (fu *fileUnit) switchFile(next *os.File, dec io.ReadCloser) error {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	var st *stat.FileStat
+	var err error
+	if dec != nil {
+		st, err = statCompressedFile(fu.log, next, fu.fingerprintSize)
+	} else {
+		st, err = statFile(next, fu.fingerprintSize)
+	}
+	if err != nil {
 		return err
 	}
+	if !fu.manualCommit {
+		if err := fu.pf.Set(st, 0); err != nil {
+			return err
+		}
+	}
+	fu.closeDecompressor()
 	if err := fu.f.Close(); err != nil {
-		logger.Printf("follow: an error occurred while closing the file: %+v", err)
+		fu.log.Error("follow: an error occurred while closing the file", pathAttr(fu.f.Name()), errAttr(err))
 	}
 	fu.f = next
+	fu.dec = dec
+	fu.readStat = st
+	fu.readOffset = 0
+	fu.resetConverter()
+	fu.startGeneration(st)
+	fu.observedSize = 0
+	fu.rotations++
+	fu.lastGrowth = time.Now()
 	return nil
 }
 
-func findSameFile(globPatterns []string, findStat *stat.FileStat) (*os.File, *stat.FileStat, os.FileInfo, error) {
+// resetConverter restarts the conversion from the readOffset. fu.mu must be held.
+func (fu *fileUnit) resetConverter() {
+	if fu.conv != nil {
+		fu.conv.reset(fu.readOffset)
+	}
+}
+
+// closeDecompressor closes the decompressing reader if any. fu.mu must be held.
+func (fu *fileUnit) closeDecompressor() {
+	if fu.dec == nil {
+		return
+	}
+	closeDecompressor(fu.log, fu.f, fu.dec)
+	fu.dec = nil
+}
+
+// statFile returns the FileStat of f, with the Fingerprint if fingerprintSize is positive
+func statFile(f *os.File, fingerprintSize int64) (*stat.FileStat, error) {
+	st, err := stat.Stat(f)
+	if err != nil || fingerprintSize <= 0 {
+		return st, err
+	}
+	fp, err := stat.ComputeFingerprint(f, fingerprintSize)
+	if err != nil {
+		return nil, err
+	}
+	st.Fingerprint = fp
+	return st, nil
+}
+
+// sameFile is the file found by findSameFile
+type sameFile struct {
+	f        *os.File
+	fileInfo os.FileInfo
+	// dec is the decompressing reader if f is the compressed rotated file
+	dec io.ReadCloser
+}
+
+// findSameFile finds the file represented by findStat from the files matching the globPatterns.
+// If findStat has the Fingerprint, the compressed rotated files are matched by the Fingerprint of the decompressed content.
+func findSameFile(log *slog.Logger, globPatterns []string, findStat *stat.FileStat) (*sameFile, error) {
 	var f *os.File
-	errAndClose := func(tErr error) (*os.File, *stat.FileStat, os.FileInfo, error) {
+	var dec io.ReadCloser
+	errAndClose := func(tErr error) (*sameFile, error) {
+		if dec != nil {
+			closeDecompressor(log, f, dec)
+		}
 		if f != nil {
 			if cErr := f.Close(); cErr != nil {
-				logger.Printf("follow: an error occurred while closing the file %s: %+v", f.Name(), cErr)
+				log.Error("follow: an error occurred while closing the file", pathAttr(f.Name()), errAttr(cErr))
 			}
 		}
-		return nil, nil, nil, tErr
+		return nil, tErr
 	}
 
 	for _, glob := range globPatterns {
@@ -288,7 +852,25 @@ func findSameFile(globPatterns []string, findStat *stat.FileStat) (*os.File, *st
 			if err != nil {
 				return errAndClose(err)
 			}
-			if !stat.SameFile(fileStat, findStat) {
+			compressed, err := isCompressed(f)
+			if err != nil {
+				return errAndClose(err)
+			}
+			var same bool
+			if compressed {
+				dec, err = openCompressedSameFile(log, f, fileStat, findStat)
+				same = dec != nil
+			} else {
+				same, err = stat.SameFileContent(f, fileStat, findStat)
+			}
+			if err != nil {
+				return errAndClose(err)
+			}
+			if !same {
+				if cErr := f.Close(); cErr != nil {
+					log.Error("follow: an error occurred while closing the file", pathAttr(f.Name()), errAttr(cErr))
+				}
+				f = nil
 				continue
 			}
 			// got same file
@@ -296,20 +878,24 @@ func findSameFile(globPatterns []string, findStat *stat.FileStat) (*os.File, *st
 			if err != nil {
 				return errAndClose(err)
 			}
-			return f, fileStat, fileInfo, nil
+			return &sameFile{f: f, fileInfo: fileInfo, dec: dec}, nil
 		}
 	}
-	return nil, nil, nil, os.ErrNotExist
+	return nil, os.ErrNotExist
 }
 
-func watchRotate(done, notify chan struct{}, fu *fileUnit, followFilePath string, opt optionFollowRotate) {
+func watchRotate(done, notify, wake chan struct{}, fu *fileUnit, followFilePath string, opt optionFollowRotate) {
+	if opt.watchMode == WatchNotify {
+		err := watchNotify(done, notify, wake, fu, followFilePath, opt)
+		if err == nil {
+			return
+		}
+		fu.log.Warn("follow: failed to watch by the notification. fall back to polling", pathAttr(followFilePath), errAttr(err))
+	}
 	if !opt.followRotate {
 		return
 	}
-	fileInfo, err := fu.fileInfo()
-	if err != nil {
-		logger.Printf("follow: failed to get FileStat %s on watchRotate: %+v", fu.fileName(), err)
-	}
+	d := newRotateDetector(fu, followFilePath)
 
 	go func() {
 		tick := time.NewTicker(opt.watchRotateInterval)
@@ -319,31 +905,61 @@ func watchRotate(done, notify chan struct{}, fu *fileUnit, followFilePath string
 			case <-done:
 				return
 			case <-tick.C:
-				if fileInfo == nil {
-					var err error
-					fileInfo, err = fu.fileInfo()
-					if err != nil {
-						logger.Printf("follow: failed to get FileStat %s on watchRotate: %+v", fu.fileName(), err)
-						continue
-					}
-				}
-				currentInfo, err := os.Stat(followFilePath)
-				if err != nil {
-					if os.IsNotExist(err) {
-						continue
-					}
-					logger.Printf("follow: failed to get current FileStat %s on watchRotate: %+v", followFilePath, err)
-					continue
-				}
-				if !os.SameFile(fileInfo, currentInfo) {
-					<-time.After(opt.detectRotateDelay)
-					select {
-					case notify <- struct{}{}:
-					case <-done:
-					}
+				fu.observeSize()
+				if d.detect() {
+					notifyRotated(done, notify, fu, followFilePath, opt)
 					return
 				}
 			}
 		}
 	}()
 }
+
+// rotateDetector detects the rotation of the followed file
+type rotateDetector struct {
+	fu             *fileUnit
+	followFilePath string
+	fileInfo       os.FileInfo
+}
+
+func newRotateDetector(fu *fileUnit, followFilePath string) *rotateDetector {
+	fileInfo, err := fu.fileInfo()
+	if err != nil {
+		fu.log.Error("follow: failed to get FileStat on watchRotate", pathAttr(fu.fileName()), errAttr(err))
+	}
+	return &rotateDetector{fu: fu, followFilePath: followFilePath, fileInfo: fileInfo}
+}
+
+// detect reports whether the file of the followFilePath is not the file currently read
+func (d *rotateDetector) detect() bool {
+	if d.fileInfo == nil {
+		var err error
+		d.fileInfo, err = d.fu.fileInfo()
+		if err != nil {
+			d.fu.log.Error("follow: failed to get FileStat on watchRotate", pathAttr(d.fu.fileName()), errAttr(err))
+			return false
+		}
+	}
+	currentInfo, err := os.Stat(d.followFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.fu.log.Error("follow: failed to get current FileStat on watchRotate", pathAttr(d.followFilePath), errAttr(err))
+			return false
+		}
+		d.fu.log.Debug("follow: the followed file does not exist on watchRotate", pathAttr(d.followFilePath))
+		return false
+	}
+	// the inode of the file currently read cannot be reused while it is opened,
+	// so the Fingerprint need not be compared here.
+	return !os.SameFile(d.fileInfo, currentInfo)
+}
+
+func notifyRotated(done, notify chan struct{}, fu *fileUnit, followFilePath string, opt optionFollowRotate) {
+	pos := fu.readPosition()
+	fu.emit(Event{Type: EventRotationDetected, Path: followFilePath, FileStat: pos.FileStat, Offset: pos.Offset})
+	<-time.After(opt.detectRotateDelay)
+	select {
+	case notify <- struct{}{}:
+	case <-done:
+	}
+}
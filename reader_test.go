@@ -2,11 +2,16 @@ package follow
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +19,7 @@ import (
 	"github.com/kei2100/follow/internal/testutil"
 	"github.com/kei2100/follow/posfile"
 	"github.com/kei2100/follow/stat"
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestNoPositionFile(t *testing.T) {
@@ -445,6 +451,669 @@ func TestMultiThreaded(t *testing.T) {
 	wantPositionFile(t, r, currentStat, 10)
 }
 
+func TestReadContext(t *testing.T) {
+	t.Run("Wait for new bytes", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		r := mustOpenReader(f.Name(), WithReadPollInterval(10*time.Millisecond))
+		defer r.Close()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			f.WriteString("foo")
+		}()
+		wantReadContext(t, r, "foo", time.Second)
+		wantPositionFile(t, r, fileStat, 3)
+	})
+
+	t.Run("Context canceled", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+
+		r := mustOpenReader(f.Name(), WithReadPollInterval(10*time.Millisecond))
+		defer r.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		n, err := r.ReadContext(ctx, make([]byte, 10))
+		if g, w := n, 0; g != w {
+			t.Errorf("n got %v, want %v", g, w)
+		}
+		if g, w := err, context.DeadlineExceeded; g != w {
+			t.Errorf("err got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Closed while waiting", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+
+		r := mustOpenReader(f.Name(), WithReadPollInterval(10*time.Millisecond))
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			r.Close()
+		}()
+		n, err := r.ReadContext(context.Background(), make([]byte, 10))
+		if g, w := n, 0; g != w {
+			t.Errorf("n got %v, want %v", g, w)
+		}
+		if g, w := err, io.EOF; g != w {
+			t.Errorf("err got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Wake up on rotate", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		old, oldStat := td.CreateFile("test.log")
+		oldc := testutil.OnceCloser{C: old}
+		defer oldc.Close()
+
+		r := mustOpenReader(old.Name(), WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0), WithReadPollInterval(time.Hour))
+		defer r.Close()
+
+		old.WriteString("old")
+		wantReadContext(t, r, "old", time.Second)
+		wantPositionFile(t, r, oldStat, 3)
+
+		oldc.Close()
+		mustRename(old.Name(), old.Name()+".bk")
+		current, currentStat := td.CreateFile(filepath.Base(old.Name()))
+		defer current.Close()
+		current.WriteString("current")
+
+		wantReadContext(t, r, "current", time.Second)
+		wantPositionFile(t, r, currentStat, 7)
+	})
+
+	t.Run("Blocking Read", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		r := mustOpenReader(f.Name(), WithBlockingRead(true), WithReadPollInterval(10*time.Millisecond))
+		defer r.Close()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			f.WriteString("foo")
+		}()
+		b := make([]byte, 3)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatalf("failed to read %+v", err)
+		}
+		if g, w := string(b), "foo"; g != w {
+			t.Errorf("got %v, want %v", g, w)
+		}
+		wantPositionFile(t, r, fileStat, 3)
+	})
+}
+
+func TestWatchNotify(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the notification is supported on linux only")
+	}
+
+	t.Run("Wake up on modify", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		r := mustOpenReader(f.Name(), WithWatchMode(WatchNotify), WithWatchRotateInterval(time.Hour), WithReadPollInterval(time.Hour))
+		defer r.Close()
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			f.WriteString("foo")
+		}()
+		wantReadContext(t, r, "foo", time.Second)
+		wantPositionFile(t, r, fileStat, 3)
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		old, oldStat := td.CreateFile("test.log")
+		oldc := testutil.OnceCloser{C: old}
+		defer oldc.Close()
+
+		r := mustOpenReader(old.Name(), WithWatchMode(WatchNotify), WithWatchRotateInterval(time.Hour), WithDetectRotateDelay(0), WithReadPollInterval(time.Hour))
+		defer r.Close()
+
+		old.WriteString("old")
+		wantReadContext(t, r, "old", time.Second)
+		wantPositionFile(t, r, oldStat, 3)
+
+		oldc.Close()
+		mustRename(old.Name(), old.Name()+".bk")
+		current, currentStat := td.CreateFile(filepath.Base(old.Name()))
+		defer current.Close()
+		current.WriteString("current")
+		wantReadContext(t, r, "current", time.Second)
+		wantPositionFile(t, r, currentStat, 7)
+
+		current.WriteString("grow")
+		wantReadContext(t, r, "grow", time.Second)
+		wantPositionFile(t, r, currentStat, 11)
+	})
+
+	t.Run("Rotate out of the watched directory", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		// the followed symlink is in the other directory than the rotated file, so the creation is not notified
+		old, _ := td.CreateFile("test.log")
+		oldc := testutil.OnceCloser{C: old}
+		defer oldc.Close()
+		linkDir := filepath.Join(td.Path, "link")
+		if err := os.Mkdir(linkDir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		link := filepath.Join(linkDir, "test.log")
+		if err := os.Symlink(old.Name(), link); err != nil {
+			t.Skipf("failed to create the symlink: %v", err)
+		}
+
+		r := mustOpenReader(link, WithWatchMode(WatchNotify), WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0), WithReadPollInterval(10*time.Millisecond))
+		defer r.Close()
+
+		old.WriteString("old")
+		wantReadContext(t, r, "old", time.Second)
+
+		oldc.Close()
+		mustRename(old.Name(), old.Name()+".bk")
+		// the move of the file is notified before the creation
+		time.Sleep(50 * time.Millisecond)
+		current, currentStat := td.CreateFile(filepath.Base(old.Name()))
+		defer current.Close()
+		current.WriteString("current")
+		wantReadContext(t, r, "current", time.Second)
+		wantPositionFile(t, r, currentStat, 7)
+	})
+}
+
+func TestManualCommit(t *testing.T) {
+	t.Run("Commit", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		r := mustOpenReader(f.Name(), WithManualCommit(true))
+		defer r.Close()
+
+		f.WriteString("foo")
+		wantReadAll(t, r, "foo")
+		wantPositionFile(t, r, fileStat, 0)
+
+		if err := r.Commit(2); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		wantPositionFile(t, r, fileStat, 2)
+
+		if err := r.Commit(4); err == nil {
+			t.Errorf("want error when the commit offset exceeds the read offset")
+		}
+		if err := r.Commit(-1); err == nil {
+			t.Errorf("want error when the commit offset is negative")
+		}
+		if err := r.Commit(1); err == nil {
+			t.Errorf("want error when the commit offset is behind the committed offset")
+		}
+		wantPositionFile(t, r, fileStat, 2)
+
+		// the offset behind the committed offset can be committed after rewinding
+		wantSeek(t, r, 0, io.SeekStart, 0)
+		wantReadAll(t, r, "foo")
+		if err := r.Commit(1); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		wantPositionFile(t, r, fileStat, 1)
+	})
+
+	t.Run("Resume from the committed position of the rotated file", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		old, oldStat := td.CreateFile("test.log")
+		oldc := testutil.OnceCloser{C: old}
+		defer oldc.Close()
+
+		positionFile := posfile.InMemory(nil, 0)
+		opts := []OptionFunc{
+			WithManualCommit(true),
+			WithPositionFile(positionFile),
+			WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, "test.log.*")}),
+			WithWatchRotateInterval(10 * time.Millisecond),
+			WithDetectRotateDelay(0),
+		}
+		r := mustOpenReader(old.Name(), opts...)
+
+		old.WriteString("old")
+		wantRead(t, r, "ol", 10*time.Millisecond, time.Second)
+		token := r.Position()
+
+		oldc.Close()
+		mustRename(old.Name(), old.Name()+".1")
+		current, currentStat := td.CreateFile(filepath.Base(old.Name()))
+		defer current.Close()
+		current.WriteString("current")
+
+		wantRead(t, r, "dcurrent", 10*time.Millisecond, time.Second)
+		if err := r.CommitUpTo(token); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		wantPositionFile(t, r, oldStat, 2)
+		r.Close()
+
+		r = mustOpenReader(current.Name(), opts...)
+		defer r.Close()
+		wantRead(t, r, "dcurrent", 10*time.Millisecond, time.Second)
+		if err := r.CommitUpTo(r.Position()); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		wantPositionFile(t, r, currentStat, 7)
+	})
+
+	t.Run("Reject the position of the rotated file after committing the current file", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		old, _ := td.CreateFile("test.log")
+		oldc := testutil.OnceCloser{C: old}
+		defer oldc.Close()
+
+		r := mustOpenReader(
+			old.Name(),
+			WithManualCommit(true),
+			WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, "test.log.*")}),
+			WithWatchRotateInterval(10*time.Millisecond),
+			WithDetectRotateDelay(0),
+		)
+		defer r.Close()
+
+		old.WriteString("old")
+		wantRead(t, r, "old", 10*time.Millisecond, time.Second)
+		token := r.Position()
+
+		oldc.Close()
+		mustRename(old.Name(), old.Name()+".1")
+		current, currentStat := td.CreateFile(filepath.Base(old.Name()))
+		defer current.Close()
+		current.WriteString("current")
+
+		wantRead(t, r, "current", 10*time.Millisecond, time.Second)
+		if err := r.CommitUpTo(r.Position()); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		if err := r.CommitUpTo(token); err == nil {
+			t.Errorf("want error when the commit position is older than the committed position")
+		}
+		wantPositionFile(t, r, currentStat, 7)
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Run("Inode reused", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foobar")
+
+		// the positionFile recorded for the deleted file whose inode is reused by test.log
+		recorded := *fileStat
+		recorded.Fingerprint = mustFingerprint(td, "xyz")
+		positionFile := posfile.InMemory(&recorded, 3)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithFingerprintSize(1024), WithReadFromHead(true))
+		defer r.Close()
+
+		wantReadAll(t, r, "foobar")
+		wantPositionFile(t, r, fileStat, 6)
+	})
+
+	t.Run("Same file", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foobar")
+
+		recorded := *fileStat
+		recorded.Fingerprint = mustFingerprint(td, "foo")
+		positionFile := posfile.InMemory(&recorded, 3)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithFingerprintSize(1024), WithReadFromHead(true))
+		defer r.Close()
+
+		wantReadAll(t, r, "bar")
+		wantPositionFile(t, r, fileStat, 6)
+		if g, w := positionFile.FileStat().Fingerprint, mustFingerprint(td, "foobar"); *g != *w {
+			t.Errorf("fingerprint got %+v, want %+v", g, w)
+		}
+	})
+}
+
+func TestCompressedRotatedFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		ext      string
+		compress func(w io.Writer) io.WriteCloser
+	}{
+		{
+			name: "gzip",
+			ext:  ".gz",
+			compress: func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			},
+		},
+		{
+			name: "zstd",
+			ext:  ".zst",
+			compress: func(w io.Writer) io.WriteCloser {
+				zw, err := zstd.NewWriter(w)
+				if err != nil {
+					panic(err)
+				}
+				return zw
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			name := "test.log"
+			old, oldStat := td.CreateFile(name)
+			old.WriteString("foobar")
+			old.Close()
+
+			// the positionFile recorded before the rotation
+			recorded := *oldStat
+			recorded.Fingerprint = mustFingerprint(td, "foobar")
+			positionFile := posfile.InMemory(&recorded, 3)
+
+			// rotated and compressed after some writes
+			rotated, _ := td.CreateFile(name + ".1" + tt.ext)
+			w := tt.compress(rotated)
+			w.Write([]byte("foobarbaz"))
+			w.Close()
+			rotated.Close()
+			mustRemoveFile(old.Name())
+
+			current, currentStat := td.CreateFile(name)
+			defer current.Close()
+			current.WriteString("qux")
+
+			r := mustOpenReader(
+				current.Name(),
+				WithPositionFile(positionFile),
+				WithFingerprintSize(1024),
+				WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+				WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+			)
+			defer r.Close()
+
+			wantRead(t, r, "barbazqux", 10*time.Millisecond, time.Second)
+			wantPositionFile(t, r, currentStat, 3)
+		})
+
+		t.Run(tt.name+" default options", func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			name := "test.log"
+			old, _ := td.CreateFile(name)
+			old.WriteString("foo")
+			positionFile := posfile.InMemory(nil, 0)
+			opts := []OptionFunc{
+				WithPositionFile(positionFile),
+				WithReadFromHead(true),
+				WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+				WithWatchRotateInterval(10 * time.Millisecond), WithDetectRotateDelay(0),
+			}
+			r := mustOpenReader(old.Name(), opts...)
+			wantRead(t, r, "foo", 10*time.Millisecond, time.Second)
+			r.Close()
+
+			// rotated and compressed while stopped
+			old.WriteString("bar")
+			old.Close()
+			rotated, _ := td.CreateFile(name + ".1" + tt.ext)
+			w := tt.compress(rotated)
+			w.Write([]byte("foobar"))
+			w.Close()
+			rotated.Close()
+			mustRemoveFile(old.Name())
+			current, currentStat := td.CreateFile(name)
+			defer current.Close()
+			current.WriteString("qux")
+
+			r = mustOpenReader(current.Name(), opts...)
+			defer r.Close()
+			wantRead(t, r, "barqux", 10*time.Millisecond, time.Second)
+			wantPositionFile(t, r, currentStat, 3)
+		})
+
+		t.Run(tt.name+" empty fingerprint", func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			name := "test.log"
+			old, oldStat := td.CreateFile(name)
+			old.Close()
+
+			// the positionFile recorded while the file was empty
+			recorded := *oldStat
+			recorded.Fingerprint = mustFingerprint(td, "")
+			positionFile := posfile.InMemory(&recorded, 0)
+
+			// the compressed files unrelated to the recorded file
+			for i, content := range []string{"unrelated1", "unrelated2"} {
+				f, _ := td.CreateFile(fmt.Sprintf("%s.%d%s", name, i+1, tt.ext))
+				w := tt.compress(f)
+				w.Write([]byte(content))
+				w.Close()
+				f.Close()
+			}
+			// the current file is created before the removal not to reuse the inode of the recorded file
+			mustRename(old.Name(), old.Name()+".bk")
+			current, currentStat := td.CreateFile(name)
+			defer current.Close()
+			current.WriteString("qux")
+			mustRemoveFile(old.Name() + ".bk")
+
+			r := mustOpenReader(
+				current.Name(),
+				WithPositionFile(positionFile),
+				WithFingerprintSize(1024),
+				WithReadFromHead(true),
+				WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+				WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+			)
+			defer r.Close()
+
+			wantRead(t, r, "qux", 10*time.Millisecond, time.Second)
+			wantPositionFile(t, r, currentStat, 3)
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Run("Read from head", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithWatchRotateInterval(10*time.Millisecond))
+		defer r.Close()
+
+		f.WriteString("foo")
+		wantReadAll(t, r, "foo")
+		f.WriteString("bar")
+		time.Sleep(100 * time.Millisecond)
+
+		// copytruncate
+		mustTruncate(f)
+		f.WriteString("baz")
+		wantReadAll(t, r, "baz")
+		wantPositionFile(t, r, fileStat, 3)
+		wantTruncation(t, positionFile, 1, 3)
+	})
+
+	t.Run("Read from end", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithTruncatePolicy(TruncateReadFromEnd), WithFollowRotate(false))
+		defer r.Close()
+
+		f.WriteString("foobar")
+		wantReadAll(t, r, "foobar")
+
+		mustTruncate(f)
+		f.WriteString("baz")
+		wantReadAll(t, r, "")
+		wantPositionFile(t, r, fileStat, 3)
+		wantTruncation(t, positionFile, 1, 3)
+
+		f.WriteString("qux")
+		wantReadAll(t, r, "qux")
+		wantPositionFile(t, r, fileStat, 6)
+	})
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile("test.log")
+	defer f.Close()
+	f.WriteString("foo")
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	positionFile := posfile.InMemory(fileStat, 5)
+	r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithLogger(l))
+	r.Close()
+
+	var rec struct {
+		Level  string `json:"level"`
+		Path   string `json:"path"`
+		Inode  uint64 `json:"inode"`
+		Offset int64  `json:"offset"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("failed to decode the log record %s: %+v", buf.String(), err)
+	}
+	_, ino := stat.ID(fileStat)
+	if rec.Level != "WARN" || rec.Path != f.Name() || rec.Inode != ino || rec.Offset != 5 {
+		t.Errorf("log record got %s", buf.String())
+	}
+}
+
+func BenchmarkRead(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		opts []posfile.OptionFunc
+	}{
+		{name: "FlushEveryRead"},
+		{name: "FlushBytes64KiB", opts: []posfile.OptionFunc{posfile.WithFlushBytes(64 * 1024)}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			f, _ := td.CreateFile("test.log")
+			defer f.Close()
+			f.Write(bytes.Repeat([]byte("a"), 512*b.N))
+
+			pf, err := WithPositionFilePath(filepath.Join(td.Path, "posfile"), bm.opts...)
+			if err != nil {
+				b.Fatalf("failed to open posfile: %+v", err)
+			}
+			r := mustOpenReader(f.Name(), pf, WithReadFromHead(true))
+			defer r.Close()
+
+			p := make([]byte, 512)
+			b.SetBytes(512)
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				if _, err := io.ReadFull(r, p); err != nil {
+					b.Fatalf("failed to read: %+v", err)
+				}
+			}
+		})
+	}
+}
+
 func mustOpenReader(name string, opt ...OptionFunc) *Reader {
 	r, err := Open(name, opt...)
 	if err != nil {
@@ -453,6 +1122,29 @@ func mustOpenReader(name string, opt ...OptionFunc) *Reader {
 	return r
 }
 
+func mustFingerprint(td *testutil.TempDir, content string) *stat.Fingerprint {
+	f, err := os.CreateTemp(td.Path, "fingerprint")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	f.WriteString(content)
+	fp, err := stat.ComputeFingerprint(f, int64(len(content)))
+	if err != nil {
+		panic(err)
+	}
+	return fp
+}
+
+func mustTruncate(f *os.File) {
+	if err := f.Truncate(0); err != nil {
+		panic(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		panic(err)
+	}
+}
+
 func mustRemoveFile(name string) {
 	if err := os.Remove(name); err != nil {
 		panic(err)
@@ -503,6 +1195,27 @@ func wantRead(t *testing.T, r *Reader, want string, interval, timeout time.Durat
 	}
 }
 
+func wantReadContext(t *testing.T, r *Reader, want string, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var buf bytes.Buffer
+
+	for buf.Len() < len(want) {
+		b := make([]byte, len(want)-buf.Len())
+		n, err := r.ReadContext(ctx, b)
+		if err != nil {
+			t.Errorf("failed to read %+v. got %s, want %s", err, buf.String(), want)
+			return
+		}
+		buf.Write(b[:n])
+	}
+	if g, w := buf.String(), want; g != w {
+		t.Errorf("got %v, want %v", g, w)
+	}
+}
+
 func wantReadAll(t *testing.T, reader io.Reader, want string) {
 	t.Helper()
 
@@ -530,3 +1243,19 @@ func wantPositionFile(t *testing.T, r *Reader, wantFileStat *stat.FileStat, want
 		t.Errorf("offset got %v, want %v", g, w)
 	}
 }
+
+func wantTruncation(t *testing.T, pf posfile.PositionFile, wantCount int, wantLostBytes int64) {
+	t.Helper()
+
+	tr := pf.(posfile.TruncationRecorder).Truncation()
+	if tr == nil {
+		t.Errorf("truncation not recorded")
+		return
+	}
+	if g, w := tr.Count, wantCount; g != w {
+		t.Errorf("truncation count got %v, want %v", g, w)
+	}
+	if g, w := tr.LostBytes, wantLostBytes; g != w {
+		t.Errorf("truncation lostBytes got %v, want %v", g, w)
+	}
+}
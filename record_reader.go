@@ -0,0 +1,187 @@
+package follow
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OpenRecordReader opens the named file and returns the follow.RecordReader
+func OpenRecordReader(name string, opts ...OptionFunc) (*RecordReader, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	// the follow.RecordReader commits the offset by itself
+	opts = append(append([]OptionFunc{}, opts...), WithManualCommit(true))
+	lr, err := OpenLineReader(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordReader(lr, !opt.manualCommit), nil
+}
+
+// RecordReader is a record-oriented follow.Reader that aggregates the lines into the record by the multilineRule,
+// such as the stack trace spanning multiple lines.
+// RecordReader advances the positionFile offset only to the end of the last record returned by ReadRecord.
+// If WithManualCommit is enabled, RecordReader does not advance the positionFile offset,
+// and the caller commits it by CommitUpTo.
+type RecordReader struct {
+	lr         *LineReader
+	opt        optionRecord
+	delim      byte
+	autoCommit bool
+	// buf is the record being aggregated, and lines is the number of the lines in buf
+	buf   []byte
+	lines int
+	// first is the first line of the record. It is kept when the record is split by the limits
+	first []byte
+	// split reports whether the last record is returned by reaching the limits
+	split bool
+	// bufEnd is the Position just after the last line in buf
+	bufEnd Position
+	// lastLineAt is the time the last line appended to buf
+	lastLineAt time.Time
+	// next is the line read ahead that does not belong to the record in buf
+	next      []byte
+	nextEnd   Position
+	hasNext   bool
+	recordEnd Position
+	mu        sync.Mutex
+}
+
+func newRecordReader(lr *LineReader, autoCommit bool) *RecordReader {
+	return &RecordReader{
+		lr:         lr,
+		opt:        lr.r.opt.optionRecord,
+		delim:      lr.r.opt.lineDelimiter,
+		autoCommit: autoCommit,
+		recordEnd:  lr.Position(),
+	}
+}
+
+// ReadRecord reads a record, whose lines are joined by the lineDelimiter.
+// ReadRecord blocks until the next record starts, the record reaches the limits, the recordFlushTimeout elapsed, or ctx is done.
+// ReadRecord returns ctx.Err() if ctx is done, and io.EOF if the follow.RecordReader is closed while waiting.
+func (rr *RecordReader) ReadRecord(ctx context.Context) ([]byte, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	for {
+		if rr.hasNext {
+			line, end := rr.next, rr.nextEnd
+			rr.next, rr.nextEnd, rr.hasNext = nil, Position{}, false
+			if record, ok, err := rr.add(line, end); ok || err != nil {
+				return record, err
+			}
+			continue
+		}
+
+		line, err := rr.readLine(ctx)
+		if err != nil {
+			if err == context.DeadlineExceeded && ctx.Err() == nil {
+				// recordFlushTimeout elapsed
+				return rr.flush(false)
+			}
+			return nil, err
+		}
+		if record, ok, err := rr.add(line, rr.lr.Position()); ok || err != nil {
+			return record, err
+		}
+	}
+}
+
+// readLine reads a line from the follow.LineReader.
+// If the buffer has a record, readLine waits no longer than the recordFlushTimeout.
+func (rr *RecordReader) readLine(ctx context.Context) ([]byte, error) {
+	if rr.opt.recordFlushTimeout <= 0 || rr.lines == 0 {
+		return rr.lr.ReadLine(ctx)
+	}
+	ctx, cancel := context.WithDeadline(ctx, rr.lastLineAt.Add(rr.opt.recordFlushTimeout))
+	defer cancel()
+	return rr.lr.ReadLine(ctx)
+}
+
+// add appends the line to the record, and returns the record if it is completed.
+// end is the Position just after the line.
+func (rr *RecordReader) add(line []byte, end Position) ([]byte, bool, error) {
+	if rr.lines == 0 {
+		if !rr.split || !rr.opt.multilineRule.continues(rr.first, line) {
+			rr.first = line
+		}
+		rr.split = false
+		rr.append(line, end)
+		if rr.full() {
+			record, err := rr.flush(true)
+			return record, true, err
+		}
+		return nil, false, nil
+	}
+
+	continues := rr.opt.multilineRule.continues(rr.first, line)
+	if !continues || rr.exceeds(line) {
+		rr.next, rr.nextEnd, rr.hasNext = line, end, true
+		record, err := rr.flush(continues)
+		return record, true, err
+	}
+	rr.append(line, end)
+	if rr.full() {
+		record, err := rr.flush(true)
+		return record, true, err
+	}
+	return nil, false, nil
+}
+
+func (rr *RecordReader) append(line []byte, end Position) {
+	if rr.lines > 0 {
+		rr.buf = append(rr.buf, rr.delim)
+	}
+	rr.buf = append(rr.buf, line...)
+	rr.lines++
+	rr.bufEnd = end
+	rr.lastLineAt = time.Now()
+}
+
+// full reports whether the record reaches the limits
+func (rr *RecordReader) full() bool {
+	return (rr.opt.maxRecordLines > 0 && rr.lines >= rr.opt.maxRecordLines) ||
+		(rr.opt.maxRecordBytes > 0 && len(rr.buf) >= rr.opt.maxRecordBytes)
+}
+
+// exceeds reports whether the record exceeds the maxRecordBytes by appending the line
+func (rr *RecordReader) exceeds(line []byte) bool {
+	return rr.opt.maxRecordBytes > 0 && len(rr.buf)+1+len(line) > rr.opt.maxRecordBytes
+}
+
+// flush returns the record in the buffer, and commits the end of the record.
+// split reports whether the record is returned by reaching the limits.
+func (rr *RecordReader) flush(split bool) ([]byte, error) {
+	record := rr.buf
+	if rr.autoCommit {
+		if err := rr.lr.CommitUpTo(rr.bufEnd); err != nil {
+			return nil, err
+		}
+	}
+	rr.recordEnd = rr.bufEnd
+	rr.buf, rr.lines, rr.split = nil, 0, split
+	return record, nil
+}
+
+// Position returns the Position just after the last record returned by ReadRecord.
+// The returned Position can be passed to CommitUpTo.
+func (rr *RecordReader) Position() Position {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	return rr.recordEnd
+}
+
+// CommitUpTo commits the Position returned by Position to the positionFile.
+// CommitUpTo is intended to be used with WithManualCommit.
+func (rr *RecordReader) CommitUpTo(pos Position) error {
+	return rr.lr.CommitUpTo(pos)
+}
+
+// Close closes the follow.RecordReader.
+// The lines that have not been returned by ReadRecord are read again from the positionFile offset at the next open.
+func (rr *RecordReader) Close() error {
+	return rr.lr.Close()
+}
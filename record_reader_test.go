@@ -0,0 +1,262 @@
+package follow
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+)
+
+func TestRecordReader(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Start pattern", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		rr := mustOpenRecordReader(
+			f.Name(),
+			WithMultilineRule(MultilineRule{StartPattern: regexp.MustCompile(`^\d`)}),
+			WithRecordFlushTimeout(0),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer rr.Close()
+
+		f.WriteString("1 foo\n  bar\n2 baz\n")
+		wantReadRecord(t, rr, "1 foo\n  bar", time.Second)
+		wantPositionFile(t, rr.lr.r, fileStat, 12)
+
+		// the last record is held until the next record starts
+		wantReadRecordTimeout(t, rr, 50*time.Millisecond)
+		wantPositionFile(t, rr.lr.r, fileStat, 12)
+
+		f.WriteString("  qux\n3 quux\n")
+		wantReadRecord(t, rr, "2 baz\n  qux", time.Second)
+		wantPositionFile(t, rr.lr.r, fileStat, 24)
+	})
+
+	t.Run("Continuation pattern with negate", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+
+		rr := mustOpenRecordReader(
+			f.Name(),
+			WithMultilineRule(MultilineRule{ContinuationPattern: regexp.MustCompile(`^\[`), Negate: true}),
+			WithRecordFlushTimeout(50*time.Millisecond),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer rr.Close()
+
+		f.WriteString("[1] foo\nbar\n[2] baz\n")
+		wantReadRecord(t, rr, "[1] foo\nbar", time.Second)
+		// the last record is returned after the recordFlushTimeout
+		wantReadRecord(t, rr, "[2] baz", time.Second)
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, _ := td.CreateFile("test.log")
+		defer f.Close()
+
+		rr := mustOpenRecordReader(
+			f.Name(),
+			WithMultilineRule(PythonTracebackRule),
+			WithMaxRecordLines(3),
+			WithMaxRecordBytes(16),
+			WithRecordFlushTimeout(50*time.Millisecond),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer rr.Close()
+
+		f.WriteString("Traceback (most recent call last):\n a\n b\n c\n dddddddddddddd\n e\nfoo\n")
+		wantReadRecord(t, rr, "Traceback (most recent call last):", time.Second)
+		// the lines following the split record are still aggregated
+		wantReadRecord(t, rr, " a\n b\n c", time.Second)
+		wantReadRecord(t, rr, " dddddddddddddd", time.Second)
+		wantReadRecord(t, rr, " e", time.Second)
+		wantReadRecord(t, rr, "foo", time.Second)
+	})
+
+	t.Run("Manual commit", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+
+		rr := mustOpenRecordReader(
+			f.Name(),
+			WithMultilineRule(JavaStackTraceRule),
+			WithManualCommit(true),
+			WithReadPollInterval(10*time.Millisecond),
+		)
+		defer rr.Close()
+
+		f.WriteString("foo\n\tat bar\nbaz\n")
+		wantReadRecord(t, rr, "foo\n\tat bar", time.Second)
+		wantPositionFile(t, rr.lr.r, fileStat, 0)
+
+		if err := rr.CommitUpTo(rr.Position()); err != nil {
+			t.Fatalf("failed to commit %+v", err)
+		}
+		wantPositionFile(t, rr.lr.r, fileStat, 12)
+	})
+}
+
+func TestRecordReaderKeepOptions(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, _ := td.CreateFile("test.log")
+	defer f.Close()
+
+	// the spare capacity of the caller's slice must not be overwritten
+	opts := make([]OptionFunc, 1, 2)
+	opts[0] = WithReadFromHead(true)
+	rr := mustOpenRecordReader(f.Name(), opts...)
+	defer rr.Close()
+	if opts[:2][1] != nil {
+		t.Errorf("the backing array of opts is modified")
+	}
+}
+
+func TestMultilineRulePresets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rule MultilineRule
+		want []string
+	}{
+		{
+			name: "Java",
+			rule: JavaStackTraceRule,
+			want: []string{
+				"2024-01-01 00:00:00 INFO started",
+				`2024-01-01 00:00:01 ERROR failed
+java.lang.IllegalStateException: outer
+	at com.example.Foo.bar(Foo.java:10)
+	at com.example.Main.main(Main.java:5)
+Caused by: java.io.IOException: inner
+	at com.example.Foo.baz(Foo.java:20)
+	... 1 more`,
+				`Exception in thread "main" java.lang.RuntimeException: boom
+	at com.example.Main.main(Main.java:3)`,
+				"2024-01-01 00:00:02 INFO stopped",
+				// not the package-qualified class name
+				"Error: disk full",
+				"IOException: closed",
+			},
+		},
+		{
+			name: "Python",
+			rule: PythonTracebackRule,
+			want: []string{
+				"ERROR:root:failed",
+				`Traceback (most recent call last):
+  File "main.py", line 3, in <module>
+    foo()
+  File "main.py", line 1, in foo
+    raise ValueError("boom")
+ValueError: boom`,
+				"INFO:root:done",
+			},
+		},
+		{
+			name: "Go",
+			rule: GoPanicRule,
+			want: []string{
+				"2024/01/01 00:00:00 started",
+				`panic: boom
+
+goroutine 1 [running]:
+main.(*T).run(0xc000012345, {0x4a1b2c, 0x3})
+	/src/main.go:12 +0x25
+main.main()
+	/src/main.go:20 +0x1d
+exit status 2`,
+				"2024/01/01 00:00:01 restarted",
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			td := testutil.CreateTempDir()
+			defer td.RemoveAll()
+
+			f, _ := td.CreateFile("test.log")
+			defer f.Close()
+
+			rr := mustOpenRecordReader(
+				f.Name(),
+				WithMultilineRule(tt.rule),
+				WithRecordFlushTimeout(50*time.Millisecond),
+				WithReadPollInterval(10*time.Millisecond),
+			)
+			defer rr.Close()
+
+			f.WriteString(strings.Join(tt.want, "\n") + "\n")
+			for _, want := range tt.want {
+				wantReadRecord(t, rr, want, time.Second)
+			}
+		})
+	}
+}
+
+func mustOpenRecordReader(name string, opt ...OptionFunc) *RecordReader {
+	rr, err := OpenRecordReader(name, opt...)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func wantReadRecord(t *testing.T, rr *RecordReader, want string, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	record, err := rr.ReadRecord(ctx)
+	if err != nil {
+		t.Errorf("failed to read record %+v", err)
+		return
+	}
+	if g, w := string(record), want; g != w {
+		t.Errorf("got %q, want %q", g, w)
+	}
+}
+
+func wantReadRecordTimeout(t *testing.T, rr *RecordReader, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	record, err := rr.ReadRecord(ctx)
+	if g, w := err, context.DeadlineExceeded; g != w {
+		t.Errorf("err got %v, want %v. record %q", g, w, record)
+	}
+}
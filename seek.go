@@ -0,0 +1,111 @@
+package follow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+var (
+	// ErrSeekOutOfRange is the cause of the SeekError when the offset is negative or past the end of the file
+	ErrSeekOutOfRange = errors.New("offset out of range")
+	// ErrSeekRotating is the cause of the SeekError when the follow.Reader is reading the remaining bytes of the rotated file
+	ErrSeekRotating = errors.New("the file is being rotated")
+	// ErrSeekCompressed is the cause of the SeekError when the file currently read is the compressed rotated file
+	ErrSeekCompressed = errors.New("the compressed file cannot be seeked")
+	// ErrSeekWhence is the cause of the SeekError when the whence is invalid
+	ErrSeekWhence = errors.New("invalid whence")
+)
+
+// SeekError is returned by Seek when the seek is not possible
+type SeekError struct {
+	Offset int64
+	Whence int
+	Err    error
+}
+
+func (e *SeekError) Error() string {
+	return fmt.Sprintf("follow: failed to seek offset %d whence %d: %v", e.Offset, e.Whence, e.Err)
+}
+
+func (e *SeekError) Unwrap() error {
+	return e.Err
+}
+
+// Seek sets the offset for the next Read in the file currently read, like io.Seeker.
+// Unless manualCommit is enabled, the offset is also recorded in the positionFile.
+// Seek returns *SeekError if the offset is negative or past the end of the file,
+// or the remaining bytes of the rotated file are being read.
+// If the encoding is not EncodingNone, the offset is moved back to the head of the line containing it,
+// so that the conversion restarts at the boundary of the characters. Seek returns the moved offset.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	return r.fu.seek(offset, whence, func() bool {
+		return atomic.LoadInt32(&r.state) == sNormal
+	})
+}
+
+// Rewind sets the offset for the next Read to the head of the file currently read.
+// Rewind is equivalent to Seek(0, io.SeekStart).
+func (r *Reader) Rewind() error {
+	_, err := r.Seek(0, io.SeekStart)
+	return err
+}
+
+// seek sets the offset of f. normal reports whether the follow.Reader is in the normal state.
+// normal is called while fu.mu is held, so that the file is not switched while seeking.
+func (fu *fileUnit) seek(offset int64, whence int, normal func() bool) (int64, error) {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	seekErr := func(err error) (int64, error) {
+		return 0, &SeekError{Offset: offset, Whence: whence, Err: err}
+	}
+	if !normal() {
+		return seekErr(ErrSeekRotating)
+	}
+	if fu.dec != nil {
+		return seekErr(ErrSeekCompressed)
+	}
+	fi, err := fu.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = fu.readOffset + offset
+	case io.SeekEnd:
+		abs = fi.Size() + offset
+	default:
+		return seekErr(ErrSeekWhence)
+	}
+	if abs < 0 || abs > fi.Size() {
+		return seekErr(ErrSeekOutOfRange)
+	}
+	if fu.conv != nil {
+		// restart the conversion at the head of the line, which is at the boundary of the characters
+		delim, err := fu.conv.sourceDelimiter(fu.head)
+		if err != nil {
+			return 0, err
+		}
+		if abs, err = headOfLine(fu.f, abs-abs%int64(len(delim)), 1, delim); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := fu.f.Seek(abs, io.SeekStart); err != nil {
+		return 0, err
+	}
+	fu.readOffset = abs
+	fu.resetConverter()
+	fu.observe(fi.Size())
+	if !fu.manualCommit {
+		if err := fu.pf.Set(fu.readStat, abs); err != nil {
+			return abs, err
+		}
+	}
+	fu.startGeneration(fu.readStat)
+	return abs, nil
+}
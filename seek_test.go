@@ -0,0 +1,155 @@
+package follow
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestSeek(t *testing.T) {
+	t.Parallel()
+
+	t.Run("seek and rewind", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foobarbaz")
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile))
+		defer r.Close()
+
+		wantReadAll(t, r, "foobarbaz")
+		wantSeek(t, r, 3, io.SeekStart, 3)
+		wantPositionFile(t, r, fileStat, 3)
+		wantReadAll(t, r, "barbaz")
+
+		wantSeek(t, r, -3, io.SeekCurrent, 6)
+		wantReadAll(t, r, "baz")
+
+		wantSeek(t, r, -6, io.SeekEnd, 3)
+		wantReadAll(t, r, "barbaz")
+
+		if err := r.Rewind(); err != nil {
+			t.Fatalf("failed to rewind: %+v", err)
+		}
+		wantPositionFile(t, r, fileStat, 0)
+		wantReadAll(t, r, "foobarbaz")
+	})
+
+	t.Run("manual commit", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foobar")
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile), WithManualCommit(true))
+		defer r.Close()
+
+		wantSeek(t, r, 3, io.SeekStart, 3)
+		wantPositionFile(t, r, fileStat, 0)
+		wantReadAll(t, r, "bar")
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foobar")
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		r := mustOpenReader(f.Name(), WithPositionFile(positionFile))
+		defer r.Close()
+
+		wantSeekError(t, r, 7, io.SeekStart, ErrSeekOutOfRange)
+		wantSeekError(t, r, 1, io.SeekEnd, ErrSeekOutOfRange)
+		wantSeekError(t, r, -1, io.SeekCurrent, ErrSeekOutOfRange)
+		wantSeekError(t, r, 0, 3, ErrSeekWhence)
+		wantPositionFile(t, r, fileStat, 0)
+		wantReadAll(t, r, "foobar")
+	})
+
+	t.Run("while rotating", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		name := "test.log"
+		f, fileStat := td.CreateFile(name)
+		fc := testutil.OnceCloser{C: f}
+		defer fc.Close()
+		f.WriteString("foo")
+
+		positionFile := posfile.InMemory(fileStat, 0)
+		r := mustOpenReader(
+			f.Name(),
+			WithPositionFile(positionFile),
+			WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+			WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+		)
+		defer r.Close()
+
+		wantReadAll(t, r, "foo")
+		f.WriteString("bar")
+		fc.Close()
+		mustRename(f.Name(), f.Name()+".1")
+		current, _ := td.CreateFile(name)
+		defer current.Close()
+		current.WriteString("baz")
+
+		// wait for the rotation to be detected, and read the remaining bytes partially
+		time.Sleep(100 * time.Millisecond)
+		p := make([]byte, 1)
+		if _, err := r.Read(p); err != nil {
+			t.Fatalf("failed to read: %+v", err)
+		}
+		wantSeekError(t, r, 0, io.SeekStart, ErrSeekRotating)
+		wantRead(t, r, "arbaz", 10*time.Millisecond, time.Second)
+		wantSeek(t, r, 0, io.SeekStart, 0)
+		wantReadAll(t, r, "baz")
+	})
+}
+
+func wantSeek(t *testing.T, r *Reader, offset int64, whence int, want int64) {
+	t.Helper()
+
+	got, err := r.Seek(offset, whence)
+	if err != nil {
+		t.Fatalf("failed to seek: %+v", err)
+	}
+	if got != want {
+		t.Errorf("offset got %v, want %v", got, want)
+	}
+}
+
+func wantSeekError(t *testing.T, r *Reader, offset int64, whence int, want error) {
+	t.Helper()
+
+	_, err := r.Seek(offset, whence)
+	var se *SeekError
+	if !errors.As(err, &se) {
+		t.Fatalf("err got %v, want *SeekError", err)
+	}
+	if !errors.Is(err, want) {
+		t.Errorf("err got %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,328 @@
+package follow
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/kei2100/follow/file"
+	"github.com/kei2100/follow/stat"
+	"golang.org/x/text/transform"
+)
+
+// TimestampExtractor extracts the timestamp of the record from the line.
+// TimestampExtractor reports false if the line has no timestamp (e.g. the continuation of the multiline record).
+// The line is converted into UTF-8 if the encoding is not EncodingNone.
+type TimestampExtractor func(line []byte) (time.Time, bool)
+
+// RegexpTimestampExtractor returns the TimestampExtractor that parses the text matched by re with the layout.
+// If re has the submatch, the first submatch is parsed. The timestamp without the time zone is parsed in loc.
+func RegexpTimestampExtractor(re *regexp.Regexp, layout string, loc *time.Location) TimestampExtractor {
+	if loc == nil {
+		loc = time.Local
+	}
+	return func(line []byte) (time.Time, bool) {
+		m := re.FindSubmatch(line)
+		if m == nil {
+			return time.Time{}, false
+		}
+		s := m[0]
+		if len(m) > 1 {
+			s = m[1]
+		}
+		t, err := time.ParseInLocation(layout, string(s), loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+}
+
+// rfc3339Pattern matches RFC 3339 like timestamps such as 2006-01-02T15:04:05Z07:00 or 2006-01-02 15:04:05.000
+var rfc3339Pattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+
+// RFC3339TimestampExtractor is the TimestampExtractor that parses the first RFC 3339 like timestamp in the line.
+// The timestamp without the time zone is parsed in the local time zone.
+func RFC3339TimestampExtractor(line []byte) (time.Time, bool) {
+	m := rfc3339Pattern.Find(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+	s := string(bytes.Replace(m, []byte{' '}, []byte{'T'}, 1))
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", s, time.Local); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// timeSearcher searches the lines of the file for the first record at or after since
+type timeSearcher struct {
+	since     time.Time
+	extractor TimestampExtractor
+	encoding  Encoding
+	delim     byte
+	log       *slog.Logger
+	// codec is the lineCodec of the file detected by firstTimestampOf
+	codec lineCodec
+}
+
+// lineCodec splits the lines in the encoding of the file, and converts them into UTF-8
+type lineCodec struct {
+	// delim is the line delimiter encoded in the encoding
+	delim []byte
+	// t is nil if the lines are not converted
+	t transform.Transformer
+}
+
+func newLineCodec(encoding Encoding, head []byte, delim byte) lineCodec {
+	e := detectEncoding(encoding, head)
+	c := lineCodec{delim: sourceDelimiter(e, delim)}
+	if tf := encodingSpecs[e].transformer; tf != nil {
+		c.t = tf()
+	}
+	return c
+}
+
+// readLine reads the line including the delimiter from br
+func (c lineCodec) readLine(br *bufio.Reader) ([]byte, error) {
+	if len(c.delim) == 1 {
+		return br.ReadBytes(c.delim[0])
+	}
+	var line []byte
+	unit := make([]byte, len(c.delim))
+	for {
+		n, err := io.ReadFull(br, unit)
+		line = append(line, unit[:n]...)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		if err != nil {
+			return line, err
+		}
+		if bytes.Equal(unit, c.delim) {
+			return line, nil
+		}
+	}
+}
+
+// skipLine skips the bytes until the delimiter in br, and returns the number of the skipped bytes
+func (c lineCodec) skipLine(br *bufio.Reader) (int64, error) {
+	if len(c.delim) > 1 {
+		line, err := c.readLine(br)
+		return int64(len(line)), err
+	}
+	var n int64
+	for {
+		skipped, err := br.ReadSlice(c.delim[0])
+		n += int64(len(skipped))
+		if err != bufio.ErrBufferFull {
+			return n, err
+		}
+	}
+}
+
+// decode converts the line into UTF-8. The BOM at the head of the file is removed
+func (c lineCodec) decode(line []byte) []byte {
+	if c.t == nil {
+		return line
+	}
+	b, _, err := transform.Bytes(c.t, line)
+	if err != nil {
+		return line
+	}
+	return bytes.TrimPrefix(b, []byte("\ufeff"))
+}
+
+// search returns the offset of the first line whose timestamp is at or after since in the first size bytes of r.
+// The timestamps in r must be in ascending order.
+// search returns size if no such line exists.
+func (s *timeSearcher) search(r io.ReaderAt, size int64) (int64, error) {
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		t, _, end, ok, err := s.firstTimestamp(r, size, mid)
+		if err != nil {
+			return 0, err
+		}
+		if !ok || !t.Before(s.since) {
+			hi = mid
+			continue
+		}
+		// the lines before end are older than since
+		lo = end
+	}
+	t, start, _, ok, err := s.firstTimestamp(r, size, lo)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || t.Before(s.since) {
+		return size, nil
+	}
+	return start, nil
+}
+
+// firstTimestamp returns the timestamp of the first line having the timestamp, searched from the head of the line
+// if the offset is at the head of the line, otherwise from the next line.
+// start and end are the offsets of the head and the end of the found line.
+func (s *timeSearcher) firstTimestamp(r io.ReaderAt, size, offset int64) (t time.Time, start, end int64, ok bool, err error) {
+	unit := int64(len(s.codec.delim))
+	offset -= offset % unit
+	if offset == 0 {
+		return s.scan(bufio.NewReader(io.NewSectionReader(r, 0, size)), 0)
+	}
+	// read from the previous code unit to know whether the offset is at the head of the line
+	pos := offset - unit
+	br := bufio.NewReader(io.NewSectionReader(r, pos, size-pos))
+	skipped, err := s.codec.skipLine(br)
+	pos += skipped
+	if err == io.EOF {
+		return time.Time{}, 0, 0, false, nil
+	}
+	if err != nil {
+		return time.Time{}, 0, 0, false, err
+	}
+	return s.scan(br, pos)
+}
+
+// scan returns the timestamp of the first line having the timestamp read from br. pos is the offset of br.
+func (s *timeSearcher) scan(br *bufio.Reader, pos int64) (t time.Time, start, end int64, ok bool, err error) {
+	for {
+		line, err := s.codec.readLine(br)
+		if len(line) > 0 {
+			start, end = pos, pos+int64(len(line))
+			pos = end
+			if t, ok := s.extractor(s.codec.decode(line)); ok {
+				return t, start, end, true, nil
+			}
+		}
+		if err == io.EOF {
+			return time.Time{}, 0, 0, false, nil
+		}
+		if err != nil {
+			return time.Time{}, 0, 0, false, err
+		}
+	}
+}
+
+// searchCompressed returns the offset of the first line whose timestamp is at or after since in the decompressed content.
+// The compressed content cannot be read at random, so the lines are scanned from the head.
+// searchCompressed returns the decompressed size if no such line exists.
+func (s *timeSearcher) searchCompressed(dec io.Reader) (int64, error) {
+	br := bufio.NewReader(dec)
+	var pos int64
+	for {
+		line, err := s.codec.readLine(br)
+		if len(line) > 0 {
+			if t, ok := s.extractor(s.codec.decode(line)); ok && !t.Before(s.since) {
+				return pos, nil
+			}
+			pos += int64(len(line))
+		}
+		if err == io.EOF {
+			return pos, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// firstTimestampOf returns the timestamp of the first line of f having the timestamp.
+// firstTimestampOf also detects the lineCodec of f used by the following search of f.
+func (s *timeSearcher) firstTimestampOf(f *os.File) (time.Time, bool, error) {
+	dec, err := openDecompressor(f)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var r io.Reader = io.NewSectionReader(f, 0, math.MaxInt64)
+	if dec != nil {
+		defer dec.Close()
+		r = dec
+	}
+	br := bufio.NewReader(r)
+	// Peek returns the shorter head with the error at the end of the file
+	head, _ := br.Peek(maxBOMLen)
+	s.codec = newLineCodec(s.encoding, head, s.delim)
+	t, _, _, ok, err := s.scan(br, 0)
+	return t, ok, err
+}
+
+// searchStartTime returns the position to start reading from the first record at or after since.
+// If the followed file f starts after since, the rotated files are searched from the newest.
+// st is nil if the position is in f.
+func searchStartTime(f *os.File, size int64, followFilePath string, opt option) (st *stat.FileStat, offset int64, err error) {
+	s := &timeSearcher{since: opt.startFromTime, extractor: opt.timestampExtractor, encoding: opt.encoding, delim: opt.lineDelimiter, log: opt.log()}
+	t, ok, err := s.firstTimestampOf(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	if (ok && t.Before(s.since)) || len(opt.rotatedFilePathPatterns) == 0 {
+		offset, err := s.search(f, size)
+		return nil, offset, err
+	}
+	files, err := listRotatedFiles(opt.rotatedFilePathPatterns, followFilePath, opt.rotatedSortKey)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		st, offset, found, err := s.searchRotatedFile(files[i].path, i == 0, opt.fingerprintSize)
+		if err != nil {
+			return nil, 0, err
+		}
+		if found {
+			return st, offset, nil
+		}
+	}
+	// no rotated files
+	offset, err = s.search(f, size)
+	return nil, offset, err
+}
+
+// searchRotatedFile returns the position of the first record at or after since in the rotated file.
+// found is false if the rotated file starts after since and is not the oldest.
+func (s *timeSearcher) searchRotatedFile(path string, oldest bool, fingerprintSize int64) (st *stat.FileStat, offset int64, found bool, err error) {
+	f, err := file.Open(path)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer f.Close()
+
+	t, ok, err := s.firstTimestampOf(f)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if !oldest && !(ok && t.Before(s.since)) {
+		return nil, 0, false, nil
+	}
+	dec, err := openDecompressor(f)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if dec == nil {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if offset, err = s.search(f, fi.Size()); err != nil {
+			return nil, 0, false, err
+		}
+		st, err = statFile(f, fingerprintSize)
+		return st, offset, err == nil, err
+	}
+	defer dec.Close()
+	if offset, err = s.searchCompressed(dec); err != nil {
+		return nil, 0, false, err
+	}
+	st, err = statCompressedFile(s.log, f, fingerprintSize)
+	return st, offset, err == nil, err
+}
@@ -0,0 +1,157 @@
+package follow
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+func TestRFC3339TimestampExtractor(t *testing.T) {
+	tests := []struct {
+		line string
+		want time.Time
+		ok   bool
+	}{
+		{line: "2024-01-02T03:04:05Z foo", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ok: true},
+		{line: "[2024-01-02 03:04:05.123+09:00] foo", want: time.Date(2024, 1, 2, 3, 4, 5, 123000000, time.FixedZone("", 9*60*60)), ok: true},
+		{line: "2024-01-02 03:04:05 foo", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local), ok: true},
+		{line: "\tat foo.Bar", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := RFC3339TimestampExtractor([]byte(tt.line))
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("%s: got %v %v, want %v %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRegexpTimestampExtractor(t *testing.T) {
+	ext := RegexpTimestampExtractor(regexp.MustCompile(`\[(\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4})\]`), "02/Jan/2006:15:04:05 -0700", nil)
+	got, ok := ext([]byte(`127.0.0.1 - - [02/Jan/2024:03:04:05 +0000] "GET / HTTP/1.1" 200`))
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("got %v %v, want %v", got, ok, want)
+	}
+}
+
+func TestSearchTime(t *testing.T) {
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprintf("%s line%d\n", timestamp(i), i))
+		if i%3 == 0 {
+			lines = append(lines, "\tcontinuation\n")
+		}
+	}
+	content := strings.Join(lines, "")
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+	f := mustCreateFileContent(td, "test.log", content)
+	defer f.Close()
+
+	tests := []struct {
+		since int
+		want  string
+	}{
+		{since: -1, want: timestamp(0) + " line0\n"},
+		{since: 0, want: timestamp(0) + " line0\n"},
+		{since: 500, want: timestamp(500) + " line500\n"},
+		{since: 999, want: timestamp(999) + " line999\n"},
+		{since: 1000, want: ""},
+	}
+	for _, tt := range tests {
+		s := &timeSearcher{since: baseTime.Add(time.Duration(tt.since) * time.Second), extractor: RFC3339TimestampExtractor, codec: newLineCodec(EncodingNone, nil, '\n')}
+		got, err := s.search(f, int64(len(content)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(content[got:], tt.want) || (tt.want == "" && got != int64(len(content))) {
+			t.Errorf("since %d: got offset %d (%q)", tt.since, got, content[got:min(int(got)+30, len(content))])
+		}
+	}
+}
+
+func TestStartFromTime(t *testing.T) {
+	t.Run("Followed file", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString(timestampLines(0, 3))
+
+		r := mustOpenReader(f.Name(), WithPositionFile(posfile.InMemory(nil, 0)), WithStartFromTime(baseTime.Add(time.Second), nil))
+		defer r.Close()
+
+		wantReadAll(t, r, timestampLines(1, 3))
+		wantPositionFile(t, r, fileStat, int64(len(timestampLines(0, 3))))
+	})
+
+	t.Run("Rotated files", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		name := "test.log"
+		gen2, _ := td.CreateFile(name + ".2.gz")
+		w := gzip.NewWriter(gen2)
+		w.Write([]byte(timestampLines(0, 3)))
+		w.Close()
+		gen2.Close()
+
+		gen1, _ := td.CreateFile(name + ".1")
+		gen1.WriteString(timestampLines(3, 6))
+		gen1.Close()
+
+		current, _ := td.CreateFile(name)
+		defer current.Close()
+		current.WriteString(timestampLines(6, 9))
+
+		r := mustOpenReader(
+			current.Name(),
+			WithPositionFile(posfile.InMemory(nil, 0)),
+			WithStartFromTime(baseTime.Add(time.Second), nil),
+			WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+			WithRotatedSortKey(RotatedSortByNumericSuffix),
+			WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+		)
+		defer r.Close()
+
+		wantRead(t, r, timestampLines(1, 9), 10*time.Millisecond, time.Second)
+	})
+}
+
+var baseTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func timestamp(sec int) string {
+	return baseTime.Add(time.Duration(sec) * time.Second).Format(time.RFC3339)
+}
+
+func timestampLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&b, "%s line%d\n", timestamp(i), i)
+	}
+	return b.String()
+}
+
+func mustCreateFileContent(td *testutil.TempDir, name, content string) *os.File {
+	w, _ := td.CreateFile(name)
+	w.WriteString(content)
+	w.Close()
+	f, err := os.Open(w.Name())
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// Datagram is the Sink that sends each record as the datagram of UDP or unixgram, without the trailing newline.
+// The datagrams are not acknowledged by the receiver, so Write returns nil after they are sent,
+// and the delivery is at most once. Open does not offer Datagram for this reason.
+type Datagram struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// DialDatagram creates the Datagram that sends to the addr on the network, udp or unixgram
+func DialDatagram(network, addr string, opts ...OptionFunc) (*Datagram, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+	d := net.Dialer{Timeout: opt.dialTimeout}
+	conn, err := d.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Datagram{conn: conn}, nil
+}
+
+// Write sends the records. Write is interrupted when ctx is done.
+// The record exceeding the max size of the datagram (EMSGSIZE) is the PermanentError, because it is never sent.
+func (d *Datagram) Write(ctx context.Context, records [][]byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deadline, _ := ctx.Deadline()
+	if err := d.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	stop := interrupt(ctx, d.conn)
+	defer stop()
+	for _, rec := range records {
+		if _, err := d.conn.Write(rec); err != nil {
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return Permanent(err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection
+func (d *Datagram) Close() error {
+	return d.conn.Close()
+}
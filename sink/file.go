@@ -0,0 +1,111 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is the Sink that appends the records to the local file.
+// The file is rotated by the size like logrotate, path is renamed to path.1, path.1 to path.2, and so on.
+// The records are acknowledged after they are synced to the disk.
+type File struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	f          *os.File
+	size       int64
+	mu         sync.Mutex
+}
+
+// OpenFile opens the File of the path
+func OpenFile(path string, opts ...OptionFunc) (*File, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	fs := &File{path: path, maxBytes: opt.fileMaxBytes, maxBackups: opt.fileMaxBackups}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *File) open() error {
+	f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fs.f = f
+	fs.size = fi.Size()
+	return nil
+}
+
+// Write appends the records to the file
+func (fs *File) Write(ctx context.Context, records [][]byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		if err := fs.open(); err != nil {
+			return err
+		}
+	}
+	buf := frame(records)
+	if fs.maxBytes > 0 && fs.size > 0 && fs.size+int64(len(buf)) > fs.maxBytes {
+		if err := fs.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := fs.f.Write(buf)
+	fs.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return fs.f.Sync()
+}
+
+// rotate renames the file to the backup, and opens the new file. fs.mu must be held.
+func (fs *File) rotate() error {
+	if err := fs.f.Close(); err != nil {
+		return err
+	}
+	fs.f = nil
+	if fs.maxBackups <= 0 {
+		if err := os.Remove(fs.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return fs.open()
+	}
+	for i := fs.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(fs.backup(i), fs.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(fs.path, fs.backup(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fs.open()
+}
+
+func (fs *File) backup(i int) string {
+	return fmt.Sprintf("%s.%d", fs.path, i)
+}
+
+// Close closes the file
+func (fs *File) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.f == nil {
+		return nil
+	}
+	err := fs.f.Close()
+	fs.f = nil
+	return err
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kei2100/follow/internal/testutil"
+)
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	path := filepath.Join(td.Path, "out.log")
+	fs, err := OpenFile(path, WithFileMaxBytes(8), WithFileMaxBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	for _, rec := range []string{"foo", "bar", "baz", "qux"} {
+		mustWrite(t, fs, rec)
+	}
+	wantFileContent(t, path, "baz\nqux\n")
+	wantFileContent(t, path+".1", "foo\nbar\n")
+
+	mustWrite(t, fs, "quux")
+	mustWrite(t, fs, "corge")
+	wantFileContent(t, path, "corge\n")
+	wantFileContent(t, path+".1", "quux\n")
+	wantFileContent(t, path+".2", "baz\nqux\n")
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup exceeding max backups exists: %v", err)
+	}
+}
+
+func mustWrite(t *testing.T, s Sink, records ...string) {
+	t.Helper()
+
+	var recs [][]byte
+	for _, rec := range records {
+		recs = append(recs, []byte(rec))
+	}
+	if err := s.Write(context.Background(), recs); err != nil {
+		t.Fatalf("failed to write %+v", err)
+	}
+}
+
+func wantFileContent(t *testing.T, path, want string) {
+	t.Helper()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("failed to read file %+v", err)
+		return
+	}
+	if g, w := string(b), want; g != w {
+		t.Errorf("%s got %q, want %q", filepath.Base(path), g, w)
+	}
+}
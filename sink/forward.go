@@ -0,0 +1,79 @@
+package sink
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/logger"
+)
+
+// LineReader reads the lines and commits the Position. *follow.LineReader implements LineReader
+type LineReader interface {
+	ReadLine(ctx context.Context) ([]byte, error)
+	Position() follow.Position
+	CommitUpTo(pos follow.Position) error
+}
+
+// Forward reads the lines from lr and writes them to s in batches, until ctx is done or lr returns the error.
+// The positionFile is advanced by CommitUpTo only after s acknowledges the batch, so the lines are delivered at least once
+// if s has the acknowledgement from the output (File, HTTP and Stream). see Sink for details.
+// If s returns the PermanentError, the batch is never accepted by retrying, so Forward logs and drops it,
+// and commits past it to keep forwarding the following lines.
+// lr should be opened with follow.WithManualCommit(true).
+func Forward(ctx context.Context, lr LineReader, s Sink, opts ...OptionFunc) error {
+	opt := option{}
+	opt.apply(opts...)
+	log := logger.Default()
+
+	var batch [][]byte
+	var end follow.Position
+	var batchStart time.Time
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.Write(ctx, batch); err != nil {
+			if !isPermanent(err) {
+				return err
+			}
+			log.Error("sink: dropped the records that are never accepted", slog.Int("records", len(batch)), slog.Any("error", err))
+		}
+		batch = nil
+		return lr.CommitUpTo(end)
+	}
+	for {
+		line, err := readLine(ctx, lr, len(batch) > 0, batchStart, opt)
+		if err != nil {
+			if err == context.DeadlineExceeded && ctx.Err() == nil {
+				// batchInterval elapsed
+				if err := flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+		if len(batch) == 0 {
+			batchStart = time.Now()
+		}
+		batch = append(batch, line)
+		end = lr.Position()
+		if len(batch) >= opt.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readLine reads the line. If the batch has lines, readLine waits no longer than the batchInterval since batchStart.
+func readLine(ctx context.Context, lr LineReader, batched bool, batchStart time.Time, opt option) ([]byte, error) {
+	if !batched || opt.batchInterval <= 0 {
+		return lr.ReadLine(ctx)
+	}
+	ctx, cancel := context.WithDeadline(ctx, batchStart.Add(opt.batchInterval))
+	defer cancel()
+	return lr.ReadLine(ctx)
+}
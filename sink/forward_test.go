@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow"
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+)
+
+// memorySink records the batches
+type memorySink struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (s *memorySink) Write(ctx context.Context, records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	var batch []string
+	for _, rec := range records {
+		batch = append(batch, string(rec))
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func TestForward(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Commit after acknowledged", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foo\nbar\nbaz\n")
+
+		pf := posfile.InMemory(fileStat, 0)
+		lr := mustOpenLineReader(t, f.Name(), pf)
+		defer lr.Close()
+
+		ms := &memorySink{}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		err := Forward(ctx, lr, ms, WithBatchSize(2), WithBatchInterval(50*time.Millisecond))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err got %v, want %v", err, context.DeadlineExceeded)
+		}
+
+		ms.mu.Lock()
+		defer ms.mu.Unlock()
+		if g, w := len(ms.batches), 2; g != w {
+			t.Fatalf("batches got %q", ms.batches)
+		}
+		if g, w := strings.Join(ms.batches[0], ","), "foo,bar"; g != w {
+			t.Errorf("batches[0] got %q", ms.batches[0])
+		}
+		if g, w := strings.Join(ms.batches[1], ","), "baz"; g != w {
+			t.Errorf("batches[1] got %q", ms.batches[1])
+		}
+		if g, w := pf.Offset(), int64(12); g != w {
+			t.Errorf("offset got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Not commit on failure", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("foo\n")
+
+		pf := posfile.InMemory(fileStat, 0)
+		lr := mustOpenLineReader(t, f.Name(), pf)
+		defer lr.Close()
+
+		ms := &memorySink{err: errors.New("failed")}
+		err := Forward(context.Background(), lr, ms, WithBatchSize(1))
+		if err != ms.err {
+			t.Errorf("err got %v, want %v", err, ms.err)
+		}
+		if g, w := pf.Offset(), int64(0); g != w {
+			t.Errorf("offset got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Drop on permanent failure", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		f, fileStat := td.CreateFile("test.log")
+		defer f.Close()
+		f.WriteString("poison\nfoo\n")
+
+		pf := posfile.InMemory(fileStat, 0)
+		lr := mustOpenLineReader(t, f.Name(), pf)
+		defer lr.Close()
+
+		// the first batch is rejected permanently, and the following batch is forwarded
+		ps := &poisonSink{poison: "poison"}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		err := Forward(ctx, lr, ps, WithBatchSize(1))
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err got %v, want %v", err, context.DeadlineExceeded)
+		}
+
+		ps.mu.Lock()
+		defer ps.mu.Unlock()
+		if g, w := len(ps.batches), 1; g != w {
+			t.Fatalf("batches got %q", ps.batches)
+		}
+		if g, w := strings.Join(ps.batches[0], ","), "foo"; g != w {
+			t.Errorf("batches[0] got %q", ps.batches[0])
+		}
+		if g, w := pf.Offset(), int64(11); g != w {
+			t.Errorf("offset got %v, want %v", g, w)
+		}
+	})
+}
+
+// poisonSink rejects the batch containing the poison record with the PermanentError
+type poisonSink struct {
+	memorySink
+	poison string
+}
+
+func (s *poisonSink) Write(ctx context.Context, records [][]byte) error {
+	for _, rec := range records {
+		if string(rec) == s.poison {
+			return Permanent(errors.New("rejected"))
+		}
+	}
+	return s.memorySink.Write(ctx, records)
+}
+
+func mustOpenLineReader(t *testing.T, name string, pf posfile.PositionFile) *follow.LineReader {
+	t.Helper()
+
+	lr, err := follow.OpenLineReader(name, follow.WithPositionFile(pf), follow.WithManualCommit(true), follow.WithReadPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lr
+}
@@ -0,0 +1,64 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTP is the Sink that posts the records to the URL in the batch, delimited by the newlines.
+// The records are acknowledged by the 2xx response.
+type HTTP struct {
+	url         string
+	client      *http.Client
+	contentType string
+}
+
+// StatusError is returned by Write of the HTTP when the response is not 2xx
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("sink: unexpected status %d", e.StatusCode)
+}
+
+// NewHTTP creates the HTTP that posts to the url
+func NewHTTP(url string, opts ...OptionFunc) *HTTP {
+	opt := option{}
+	opt.apply(opts...)
+
+	return &HTTP{url: url, client: opt.httpClient, contentType: opt.httpContentType}
+}
+
+// Write posts the records.
+// The 4xx response other than 408 and 429 is the PermanentError, because the same request is never accepted.
+func (h *HTTP) Write(ctx context.Context, records [][]byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(frame(records)))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", h.contentType)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = &StatusError{StatusCode: resp.StatusCode}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
+
+// Close does nothing
+func (h *HTTP) Close() error {
+	return nil
+}
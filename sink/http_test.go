@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTP(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var bodies []string
+	statuses := []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusBadRequest}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.Header.Get("Content-Type")+" "+string(b))
+		w.WriteHeader(statuses[0])
+		statuses = statuses[1:]
+	}))
+	defer ts.Close()
+
+	s, err := Open(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = Retry(s, WithRetryInitialInterval(10*time.Millisecond))
+	defer s.Close()
+
+	// 503 is retried
+	mustWrite(t, s, "foo", "bar")
+
+	// 400 is not retried
+	err = s.Write(context.Background(), [][]byte{[]byte("baz")})
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadRequest || !isPermanent(err) {
+		t.Errorf("err got %v, want the permanent StatusError", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"text/plain; charset=utf-8 foo\nbar\n",
+		"text/plain; charset=utf-8 foo\nbar\n",
+		"text/plain; charset=utf-8 baz\n",
+	}
+	if len(bodies) != len(want) {
+		t.Fatalf("bodies got %q, want %q", bodies, want)
+	}
+	for i := range want {
+		if bodies[i] != want[i] {
+			t.Errorf("bodies[%d] got %q, want %q", i, bodies[i], want[i])
+		}
+	}
+}
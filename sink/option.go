@@ -0,0 +1,137 @@
+package sink
+
+import (
+	"net/http"
+	"time"
+)
+
+type option struct {
+	ackTimeout           time.Duration
+	batchInterval        time.Duration
+	batchSize            int
+	dialTimeout          time.Duration
+	fileMaxBackups       int
+	fileMaxBytes         int64
+	httpClient           *http.Client
+	httpContentType      string
+	maxRetries           int
+	retryInitialInterval time.Duration
+	retryMaxInterval     time.Duration
+}
+
+// OptionFunc let you change Sink and Forward behavior.
+type OptionFunc func(o *option)
+
+// Default values
+const (
+	DefaultAckTimeout           = 10 * time.Second
+	DefaultBatchInterval        = time.Second
+	DefaultBatchSize            = 100
+	DefaultDialTimeout          = 10 * time.Second
+	DefaultFileMaxBackups       = 5
+	DefaultFileMaxBytes         = int64(100 * 1024 * 1024)
+	DefaultHTTPContentType      = "text/plain; charset=utf-8"
+	DefaultMaxRetries           = 0
+	DefaultRetryInitialInterval = 100 * time.Millisecond
+	DefaultRetryMaxInterval     = 30 * time.Second
+)
+
+func (o *option) apply(opts ...OptionFunc) {
+	o.ackTimeout = DefaultAckTimeout
+	o.batchInterval = DefaultBatchInterval
+	o.batchSize = DefaultBatchSize
+	o.dialTimeout = DefaultDialTimeout
+	o.fileMaxBackups = DefaultFileMaxBackups
+	o.fileMaxBytes = DefaultFileMaxBytes
+	o.httpClient = http.DefaultClient
+	o.httpContentType = DefaultHTTPContentType
+	o.maxRetries = DefaultMaxRetries
+	o.retryInitialInterval = DefaultRetryInitialInterval
+	o.retryMaxInterval = DefaultRetryMaxInterval
+	for _, fn := range opts {
+		fn(o)
+	}
+}
+
+// WithAckTimeout let you change ackTimeout of the Stream.
+// Write fails if the records are not acknowledged within ackTimeout. Zero means no limit
+func WithAckTimeout(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.ackTimeout = v
+	}
+}
+
+// WithBatchInterval let you change batchInterval of Forward.
+// The lines are written at least batchInterval after the first line of the batch is read
+func WithBatchInterval(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.batchInterval = v
+	}
+}
+
+// WithBatchSize let you change batchSize of Forward.
+// The lines are written when batchSize lines are read
+func WithBatchSize(v int) OptionFunc {
+	return func(o *option) {
+		o.batchSize = v
+	}
+}
+
+// WithDialTimeout let you change dialTimeout of the Stream
+func WithDialTimeout(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.dialTimeout = v
+	}
+}
+
+// WithFileMaxBackups let you change fileMaxBackups of the File.
+// fileMaxBackups is the number of the rotated files kept. Zero means the file is removed on the rotation
+func WithFileMaxBackups(v int) OptionFunc {
+	return func(o *option) {
+		o.fileMaxBackups = v
+	}
+}
+
+// WithFileMaxBytes let you change fileMaxBytes of the File.
+// The file is rotated before its size exceeds fileMaxBytes. Zero means the file is never rotated
+func WithFileMaxBytes(v int64) OptionFunc {
+	return func(o *option) {
+		o.fileMaxBytes = v
+	}
+}
+
+// WithHTTPClient let you change httpClient of the HTTP
+func WithHTTPClient(v *http.Client) OptionFunc {
+	return func(o *option) {
+		o.httpClient = v
+	}
+}
+
+// WithHTTPContentType let you change httpContentType of the HTTP
+func WithHTTPContentType(v string) OptionFunc {
+	return func(o *option) {
+		o.httpContentType = v
+	}
+}
+
+// WithMaxRetries let you change maxRetries of Retry. Zero means no limit
+func WithMaxRetries(v int) OptionFunc {
+	return func(o *option) {
+		o.maxRetries = v
+	}
+}
+
+// WithRetryInitialInterval let you change retryInitialInterval of Retry.
+// The interval between the retries starts from retryInitialInterval and doubles up to retryMaxInterval
+func WithRetryInitialInterval(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.retryInitialInterval = v
+	}
+}
+
+// WithRetryMaxInterval let you change retryMaxInterval of Retry
+func WithRetryMaxInterval(v time.Duration) OptionFunc {
+	return func(o *option) {
+		o.retryMaxInterval = v
+	}
+}
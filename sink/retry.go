@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/kei2100/follow/logger"
+)
+
+// retrySink retries Write of the Sink
+type retrySink struct {
+	s   Sink
+	opt option
+	log *slog.Logger
+}
+
+// Retry returns the Sink that retries Write of s with the exponential backoff until it succeeds,
+// ctx is done, the maxRetries is reached, or s returns the PermanentError.
+func Retry(s Sink, opts ...OptionFunc) Sink {
+	opt := option{}
+	opt.apply(opts...)
+
+	return &retrySink{s: s, opt: opt, log: logger.Default()}
+}
+
+func (r *retrySink) Write(ctx context.Context, records [][]byte) error {
+	interval := r.opt.retryInitialInterval
+	for retries := 0; ; retries++ {
+		err := r.s.Write(ctx, records)
+		if err == nil || isPermanent(err) || ctx.Err() != nil {
+			return err
+		}
+		if r.opt.maxRetries > 0 && retries >= r.opt.maxRetries {
+			return err
+		}
+		r.log.Warn("sink: failed to write. retry later", slog.Duration("interval", interval), slog.Int("retries", retries), slog.Any("error", err))
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		interval *= 2
+		if interval > r.opt.retryMaxInterval {
+			interval = r.opt.retryMaxInterval
+		}
+	}
+}
+
+func (r *retrySink) Close() error {
+	return r.s.Close()
+}
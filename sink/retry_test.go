@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failSink fails Write the number of times
+type failSink struct {
+	fails  int
+	err    error
+	writes int
+}
+
+func (s *failSink) Write(ctx context.Context, records [][]byte) error {
+	s.writes++
+	if s.writes <= s.fails {
+		return s.err
+	}
+	return nil
+}
+
+func (s *failSink) Close() error {
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Succeeded", func(t *testing.T) {
+		t.Parallel()
+
+		fs := &failSink{fails: 2, err: errors.New("failed")}
+		mustWrite(t, Retry(fs, WithRetryInitialInterval(time.Millisecond)), "foo")
+		if g, w := fs.writes, 3; g != w {
+			t.Errorf("writes got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Max retries", func(t *testing.T) {
+		t.Parallel()
+
+		fs := &failSink{fails: 10, err: errors.New("failed")}
+		err := Retry(fs, WithRetryInitialInterval(time.Millisecond), WithMaxRetries(2)).Write(context.Background(), [][]byte{[]byte("foo")})
+		if err != fs.err {
+			t.Errorf("err got %v, want %v", err, fs.err)
+		}
+		if g, w := fs.writes, 3; g != w {
+			t.Errorf("writes got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		t.Parallel()
+
+		fs := &failSink{fails: 10, err: Permanent(errors.New("failed"))}
+		if err := Retry(fs).Write(context.Background(), [][]byte{[]byte("foo")}); err != fs.err {
+			t.Errorf("err got %v, want %v", err, fs.err)
+		}
+		if g, w := fs.writes, 1; g != w {
+			t.Errorf("writes got %v, want %v", g, w)
+		}
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		t.Parallel()
+
+		fs := &failSink{fails: 10, err: errors.New("failed")}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if err := Retry(fs, WithRetryInitialInterval(time.Hour)).Write(ctx, [][]byte{[]byte("foo")}); err != fs.err {
+			t.Errorf("err got %v, want %v", err, fs.err)
+		}
+	})
+}
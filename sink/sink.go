@@ -0,0 +1,107 @@
+// Package sink writes the lines read by the follow.LineReader to the outputs,
+// such as the local file, the TCP, UDP and Unix sockets, and the HTTP endpoint.
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Sink writes the records to the output.
+// Write returns nil only after the output acknowledges the records, so that the caller can advance the positionFile.
+// Datagram has no acknowledgement from the receiver, and the records may be lost after Write returns.
+type Sink interface {
+	// Write writes the records. Each record is written with the trailing newline
+	Write(ctx context.Context, records [][]byte) error
+	// Close closes the Sink
+	Close() error
+}
+
+// PermanentError is the error that is not resolved by retrying
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err as the PermanentError, so that Retry does not retry
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func isPermanent(err error) bool {
+	var pe *PermanentError
+	return errors.As(err, &pe)
+}
+
+// Open opens the Sink by the URL.
+//
+//	file:///path/to/file?max_bytes=10485760&max_backups=5  File
+//	tcp://host:port                                        Stream of TCP
+//	unix:///path/to/socket                                 Stream of the Unix socket
+//	http://host/path, https://host/path                    HTTP
+//
+// Open opens only the Sink acknowledged by the output. Use DialDatagram for UDP, which may lose the records.
+func Open(rawURL string, opts ...OptionFunc) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		path := u.Path
+		if u.Opaque != "" {
+			// file:relative/path
+			path = u.Opaque
+		}
+		q := u.Query()
+		if v := q.Get("max_bytes"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("sink: invalid max_bytes %s", v)
+			}
+			opts = append(opts, WithFileMaxBytes(n))
+		}
+		if v := q.Get("max_backups"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("sink: invalid max_backups %s", v)
+			}
+			opts = append(opts, WithFileMaxBackups(n))
+		}
+		return OpenFile(path, opts...)
+	case "tcp":
+		return DialStream("tcp", u.Host, opts...)
+	case "unix":
+		return DialStream("unix", u.Path, opts...)
+	case "udp":
+		return nil, fmt.Errorf("sink: udp has no acknowledgement. use DialDatagram")
+	case "http", "https":
+		return NewHTTP(rawURL, opts...), nil
+	default:
+		return nil, fmt.Errorf("sink: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// frame returns the records joined with the trailing newlines
+func frame(records [][]byte) []byte {
+	var n int
+	for _, rec := range records {
+		n += len(rec) + 1
+	}
+	buf := make([]byte, 0, n)
+	for _, rec := range records {
+		buf = append(buf, rec...)
+		buf = append(buf, '\n')
+	}
+	return buf
+}
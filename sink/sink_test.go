@@ -0,0 +1,34 @@
+package sink
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kei2100/follow/internal/testutil"
+)
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	s, err := Open("file://" + filepath.ToSlash(filepath.Join(td.Path, "out.log")) + "?max_bytes=10&max_backups=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	fs, ok := s.(*File)
+	if !ok {
+		t.Fatalf("sink got %T, want *File", s)
+	}
+	if fs.maxBytes != 10 || fs.maxBackups != 3 {
+		t.Errorf("file got %+v", fs)
+	}
+
+	for _, u := range []string{"foo://bar", "file:///tmp/out.log?max_bytes=x", "tcp-x://host", "udp://127.0.0.1:514"} {
+		if _, err := Open(u); err == nil {
+			t.Errorf("%s err got nil", u)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// Stream is the Sink that writes the records to the stream socket of TCP or Unix.
+// The connection is established on the first Write, and re-established on the next Write after the failure.
+// The receiver acknowledges each record by replying with one line (e.g. "\n"), and Write returns nil
+// only after all the records are acknowledged, so the delivery is at least once.
+type Stream struct {
+	network     string
+	addr        string
+	dialTimeout time.Duration
+	ackTimeout  time.Duration
+	conn        net.Conn
+	acks        *bufio.Reader
+	mu          sync.Mutex
+}
+
+// DialStream creates the Stream that connects to the addr on the network, tcp or unix
+func DialStream(network, addr string, opts ...OptionFunc) (*Stream, error) {
+	opt := option{}
+	opt.apply(opts...)
+
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+	return &Stream{network: network, addr: addr, dialTimeout: opt.dialTimeout, ackTimeout: opt.ackTimeout}, nil
+}
+
+// Write writes the records to the socket, and waits for the acknowledgements no longer than the ackTimeout.
+// Write is interrupted when ctx is done, even if the receiver stalls without the deadline of ctx.
+// If Write fails, the connection is closed, so that the late acknowledgements are not taken for the next records.
+func (s *Stream) Write(ctx context.Context, records [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		d := net.Dialer{Timeout: s.dialTimeout}
+		conn, err := d.DialContext(ctx, s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		s.acks = bufio.NewReader(conn)
+	}
+	deadline, _ := ctx.Deadline()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		s.closeConn()
+		return err
+	}
+	stop := interrupt(ctx, s.conn)
+	defer stop()
+	if _, err := s.conn.Write(frame(records)); err != nil {
+		// reconnect on the next Write
+		s.closeConn()
+		return err
+	}
+	if err := s.waitAcks(len(records), deadline); err != nil {
+		s.closeConn()
+		return err
+	}
+	return nil
+}
+
+// waitAcks reads n acknowledgement lines. s.mu must be held.
+func (s *Stream) waitAcks(n int, deadline time.Time) error {
+	if s.ackTimeout > 0 {
+		if t := time.Now().Add(s.ackTimeout); deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+	if err := s.conn.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if _, err := s.acks.ReadString('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// interrupt unblocks the Read and Write of conn when ctx is done. The returned stop function stops it
+func interrupt(ctx context.Context, conn net.Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		// the deadline in the past fails the blocked Read and Write immediately
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+// closeConn closes the connection. s.mu must be held.
+func (s *Stream) closeConn() {
+	s.conn.Close()
+	s.conn = nil
+	s.acks = nil
+}
+
+// Close closes the connection
+func (s *Stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	s.acks = nil
+	return err
+}
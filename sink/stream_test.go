@@ -0,0 +1,246 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+)
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	t.Run("TCP reconnect", func(t *testing.T) {
+		t.Parallel()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		lines := acceptLines(ln)
+
+		s, err := DialStream("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		mustWrite(t, s, "foo", "bar")
+		wantLines(t, lines, "foo", "bar")
+
+		// the broken connection is re-established on the next Write
+		s.conn.Close()
+		if err := s.Write(context.Background(), [][]byte{[]byte("lost")}); err == nil {
+			t.Errorf("err got nil, want the write error")
+		}
+		mustWrite(t, s, "baz")
+		wantLines(t, lines, "baz")
+	})
+
+	t.Run("Unix", func(t *testing.T) {
+		t.Parallel()
+
+		td := testutil.CreateTempDir()
+		defer td.RemoveAll()
+
+		ln, err := net.Listen("unix", filepath.Join(td.Path, "sock"))
+		if err != nil {
+			t.Skipf("unix socket not available: %v", err)
+		}
+		defer ln.Close()
+		lines := acceptLines(ln)
+
+		s, err := Open("unix://" + ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		mustWrite(t, s, "foo")
+		wantLines(t, lines, "foo")
+	})
+
+	t.Run("Interrupted by ctx", func(t *testing.T) {
+		t.Parallel()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		done := make(chan struct{})
+		defer close(done)
+		// the receiver stalls without reading
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			<-done
+			conn.Close()
+		}()
+
+		s, err := DialStream("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- s.Write(ctx, [][]byte{bytes.Repeat([]byte("x"), 64*1024*1024)})
+		}()
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Errorf("err got nil, want the write error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Write was not interrupted by ctx")
+		}
+	})
+
+	t.Run("Not acknowledged", func(t *testing.T) {
+		t.Parallel()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		received := make(chan string, 1)
+		// the receiver reads the line without the acknowledgement
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			received <- line
+			io.Copy(io.Discard, conn)
+		}()
+
+		s, err := DialStream("tcp", ln.Addr().String(), WithAckTimeout(100*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+
+		if err := s.Write(context.Background(), [][]byte{[]byte("foo")}); err == nil {
+			t.Errorf("err got nil, want the timeout of the acknowledgement")
+		}
+		if g, w := <-received, "foo\n"; g != w {
+			t.Errorf("received got %q, want %q", g, w)
+		}
+	})
+
+	t.Run("Unknown network", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := DialStream("udp", "127.0.0.1:0"); err == nil {
+			t.Errorf("err got nil")
+		}
+	})
+}
+
+func TestDatagram(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := DialDatagram("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	mustWrite(t, s, "foo", "bar")
+	buf := make([]byte, 1024)
+	for _, want := range []string{"foo", "bar"} {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("failed to read datagram %+v", err)
+		}
+		if g, w := string(buf[:n]), want; g != w {
+			t.Errorf("got %q, want %q", g, w)
+		}
+	}
+}
+
+func TestDatagramOversized(t *testing.T) {
+	t.Parallel()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := DialDatagram("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the record exceeding the max size of the UDP datagram is not retried
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = Retry(s).Write(ctx, [][]byte{bytes.Repeat([]byte("x"), 70000)})
+	if !isPermanent(err) {
+		t.Errorf("err got %v, want the PermanentError", err)
+	}
+}
+
+// acceptLines accepts the connections, sends the lines received and acknowledges them
+func acceptLines(ln net.Listener) <-chan string {
+	lines := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				sc := bufio.NewScanner(conn)
+				for sc.Scan() {
+					lines <- sc.Text()
+					if _, err := conn.Write([]byte("\n")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return lines
+}
+
+func wantLines(t *testing.T, lines <-chan string, want ...string) {
+	t.Helper()
+
+	for _, w := range want {
+		select {
+		case g := <-lines:
+			if g != w {
+				t.Errorf("got %q, want %q", g, w)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout. want %q", w)
+		}
+	}
+}
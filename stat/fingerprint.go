@@ -0,0 +1,55 @@
+package stat
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Fingerprint is the fingerprint of the content at the head of the file.
+// It distinguishes the files that have the same device and inode because of the inode reuse.
+type Fingerprint struct {
+	// Size is the number of bytes hashed
+	Size int64 `json:"size"`
+	// Sum is the hex encoded SHA-256 of the first Size bytes
+	Sum string `json:"sum"`
+}
+
+// ComputeFingerprint returns the Fingerprint of the first maxSize bytes of the file.
+// If the file is smaller than maxSize, the Fingerprint of the whole file is returned.
+func ComputeFingerprint(file *os.File, maxSize int64) (*Fingerprint, error) {
+	return ComputeFingerprintReader(io.NewSectionReader(file, 0, maxSize), maxSize)
+}
+
+// ComputeFingerprintReader returns the Fingerprint of the first maxSize bytes read from r.
+// It is useful to compute the Fingerprint of the decompressed content.
+func ComputeFingerprintReader(r io.Reader, maxSize int64) (*Fingerprint, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(r, maxSize))
+	if err != nil {
+		return nil, err
+	}
+	return &Fingerprint{Size: n, Sum: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// MatchFingerprint reports whether the first fp.Size bytes of the file matches fp
+func MatchFingerprint(file *os.File, fp *Fingerprint) (bool, error) {
+	got, err := ComputeFingerprint(file, fp.Size)
+	if err != nil {
+		return false, err
+	}
+	return *got == *fp, nil
+}
+
+// SameFileContent reports whether the file, whose FileStat is st, is the same file represented by recorded.
+// In addition to SameFile, the Fingerprint of recorded is compared if available.
+func SameFileContent(file *os.File, st, recorded *FileStat) (bool, error) {
+	if !SameFile(st, recorded) {
+		return false, nil
+	}
+	if recorded.Fingerprint == nil {
+		return true, nil
+	}
+	return MatchFingerprint(file, recorded.Fingerprint)
+}
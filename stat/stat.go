@@ -11,3 +11,13 @@ func Stat(file *os.File) (*FileStat, error) {
 func SameFile(st1, st2 *FileStat) bool {
 	return st1.sameFile(st2)
 }
+
+// ID returns the device and the inode (the volume serial number and the file index on Windows) of st
+func ID(st *FileStat) (dev, ino uint64) {
+	return st.id()
+}
+
+// FromID returns the FileStat that has the device and the inode returned by ID
+func FromID(dev, ino uint64) *FileStat {
+	return fromID(dev, ino)
+}
@@ -28,3 +28,52 @@ func TestSameFile(t *testing.T) {
 		t.Errorf("stat newstat are the same")
 	}
 }
+
+func TestFromID(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo-file")
+	file.Close()
+
+	dev, ino := ID(fileStat)
+	if !SameFile(fileStat, FromID(dev, ino)) {
+		t.Errorf("stat FromID(ID(stat)) are the not same")
+	}
+}
+
+func TestSameFileContent(t *testing.T) {
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	file, fileStat := td.CreateFile("foo-file")
+	defer file.Close()
+	file.WriteString("foo")
+
+	reader, err := os.Open(file.Name())
+	if err != nil {
+		t.Fatalf("failed to open: %+v", err)
+	}
+	defer reader.Close()
+
+	fp, err := ComputeFingerprint(reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to compute fingerprint: %+v", err)
+	}
+	if g, w := fp.Size, int64(3); g != w {
+		t.Errorf("fingerprint size got %v, want %v", g, w)
+	}
+	recorded := *fileStat
+	recorded.Fingerprint = fp
+
+	file.WriteString("bar")
+	if same, err := SameFileContent(reader, fileStat, &recorded); err != nil || !same {
+		t.Errorf("grown file are the not same. err %v", err)
+	}
+
+	file.Truncate(0)
+	file.WriteAt([]byte("baz"), 0)
+	if same, err := SameFileContent(reader, fileStat, &recorded); err != nil || same {
+		t.Errorf("file with different content are the same. err %v", err)
+	}
+}
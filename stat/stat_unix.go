@@ -27,6 +27,8 @@ func stat(file *os.File) (*FileStat, error) {
 // FileStat is a os specific file stat
 type FileStat struct {
 	Sys syscall.Stat_t
+	// Fingerprint is the optional Fingerprint of the file
+	Fingerprint *Fingerprint
 }
 
 // See
@@ -36,3 +38,19 @@ type FileStat struct {
 func (s *FileStat) sameFile(other *FileStat) bool {
 	return s.Sys.Dev == other.Sys.Dev && s.Sys.Ino == other.Sys.Ino
 }
+
+func (s *FileStat) id() (dev, ino uint64) {
+	return uint64(s.Sys.Dev), uint64(s.Sys.Ino)
+}
+
+func fromID(dev, ino uint64) *FileStat {
+	var st FileStat
+	setUint(&st.Sys.Dev, dev)
+	setUint(&st.Sys.Ino, ino)
+	return &st
+}
+
+// setUint sets v to the field of syscall.Stat_t whose type differs between platforms
+func setUint[T ~int32 | ~uint32 | ~int64 | ~uint64](field *T, v uint64) {
+	*field = T(v)
+}
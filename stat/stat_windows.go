@@ -27,9 +27,23 @@ type FileStat struct {
 	Vol   uint32
 	IdxHi uint32
 	IdxLo uint32
+	// Fingerprint is the optional Fingerprint of the file
+	Fingerprint *Fingerprint
 }
 
 // porting from os.sameFile
 func (s *FileStat) sameFile(other *FileStat) bool {
 	return s.Vol == other.Vol && s.IdxHi == other.IdxHi && s.IdxLo == other.IdxLo
 }
+
+func (s *FileStat) id() (dev, ino uint64) {
+	return uint64(s.Vol), uint64(s.IdxHi)<<32 | uint64(s.IdxLo)
+}
+
+func fromID(dev, ino uint64) *FileStat {
+	return &FileStat{
+		Vol:   uint32(dev),
+		IdxHi: uint32(ino >> 32),
+		IdxLo: uint32(ino),
+	}
+}
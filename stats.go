@@ -0,0 +1,93 @@
+package follow
+
+import (
+	"time"
+
+	"github.com/kei2100/follow/posfile"
+	"github.com/kei2100/follow/stat"
+)
+
+// Stats is the statistics of the follow.Reader
+type Stats struct {
+	// BytesRead is the number of bytes read since the follow.Reader is opened
+	BytesRead int64
+	// LinesRead is the number of the lineDelimiter read since the follow.Reader is opened
+	LinesRead int64
+	// Path is the path of the file currently read
+	Path string
+	// Inode is the inode of the file currently read
+	Inode uint64
+	// ReadOffset is the offset just after the bytes read so far
+	ReadOffset int64
+	// CommittedOffset is the offset recorded in the positionFile.
+	// It is behind ReadOffset if manualCommit is enabled and the read bytes are not committed yet
+	CommittedOffset int64
+	// Size is the size of the file currently read
+	Size int64
+	// LagBytes is the number of bytes not read yet in the file currently read.
+	// LagBytes is -1 while reading the compressed rotated file, because the decompressed size is unknown
+	LagBytes int64
+	// Rotations is the number of the files switched by following the rotation
+	Rotations int
+	// PositionFlushes is the number of the writes of the positionFile to the disk.
+	// It is zero if the positionFile is in-memory
+	PositionFlushes int64
+	// PositionFileName is the name of the file the positionFile is written to.
+	// It is empty if the positionFile is in-memory. The follow.Readers sharing the posfile.Store
+	// have the same PositionFileName and PositionFlushes
+	PositionFileName string
+	// LastReadTime is the time when the bytes were read last. zero if nothing is read yet
+	LastReadTime time.Time
+	// SinceLastGrowth is the duration since the size of the file currently read grew last
+	SinceLastGrowth time.Duration
+}
+
+// Stats returns the statistics of the follow.Reader
+func (r *Reader) Stats() (Stats, error) {
+	return r.fu.stats()
+}
+
+func (fu *fileUnit) stats() (Stats, error) {
+	fu.mu.Lock()
+	defer fu.mu.Unlock()
+
+	fi, err := fu.f.Stat()
+	if err != nil {
+		return Stats{}, err
+	}
+	st := Stats{
+		BytesRead:       fu.bytesRead,
+		LinesRead:       fu.linesRead,
+		Path:            fu.f.Name(),
+		ReadOffset:      fu.readOffset,
+		CommittedOffset: fu.pf.Offset(),
+		Size:            fi.Size(),
+		LagBytes:        -1,
+		Rotations:       fu.rotations,
+		LastReadTime:    fu.lastRead,
+	}
+	fst, err := stat.Stat(fu.f)
+	if err != nil {
+		return Stats{}, err
+	}
+	_, st.Inode = stat.ID(fst)
+	if fc, ok := fu.pf.(posfile.FlushCounter); ok {
+		st.PositionFlushes = fc.FlushCount()
+	}
+	if fn, ok := fu.pf.(posfile.FileNamer); ok {
+		st.PositionFileName = fn.FileName()
+	}
+	st.SinceLastGrowth = time.Since(fu.lastGrowth)
+	if fu.dec == nil {
+		st.LagBytes = fi.Size() - fu.readOffset
+		if st.LagBytes < 0 {
+			// truncated but not detected yet
+			st.LagBytes = 0
+		}
+		// the growth is not recorded here, so that Stats does not affect the detection of the truncation
+		if fi.Size() > fu.observedSize {
+			st.SinceLastGrowth = 0
+		}
+	}
+	return st, nil
+}
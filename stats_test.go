@@ -0,0 +1,93 @@
+package follow
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kei2100/follow/internal/testutil"
+	"github.com/kei2100/follow/posfile"
+	"github.com/kei2100/follow/stat"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	name := "test.log"
+	f, fileStat := td.CreateFile(name)
+	fc := testutil.OnceCloser{C: f}
+	defer fc.Close()
+	f.WriteString("foo")
+
+	positionFile := posfile.InMemory(fileStat, 0)
+	r := mustOpenReader(
+		f.Name(),
+		WithPositionFile(positionFile),
+		WithManualCommit(true),
+		WithRotatedFilePathPatterns([]string{filepath.Join(td.Path, name+".*")}),
+		WithWatchRotateInterval(10*time.Millisecond), WithDetectRotateDelay(0),
+	)
+	defer r.Close()
+
+	wantReadAll(t, r, "foo")
+	f.WriteString("bar")
+	_, ino := stat.ID(fileStat)
+	wantStats(t, r, Stats{BytesRead: 3, Path: f.Name(), Inode: ino, ReadOffset: 3, CommittedOffset: 0, Size: 6, LagBytes: 3})
+
+	// rotate
+	fc.Close()
+	mustRename(f.Name(), f.Name()+".1")
+	current, currentStat := td.CreateFile(name)
+	defer current.Close()
+	current.WriteString("baz")
+
+	wantRead(t, r, "barbaz", 10*time.Millisecond, time.Second)
+	_, ino = stat.ID(currentStat)
+	wantStats(t, r, Stats{BytesRead: 9, Path: current.Name(), Inode: ino, ReadOffset: 3, CommittedOffset: 0, Size: 3, LagBytes: 0, Rotations: 1})
+}
+
+func TestStatsNoSideEffect(t *testing.T) {
+	t.Parallel()
+
+	td := testutil.CreateTempDir()
+	defer td.RemoveAll()
+
+	f, fileStat := td.CreateFile("test.log")
+	defer f.Close()
+
+	r := mustOpenReader(f.Name(), WithPositionFile(posfile.InMemory(fileStat, 0)), WithWatchRotateInterval(time.Hour))
+	defer r.Close()
+
+	f.WriteString("foo")
+	observed := r.fu.observedSize
+	st, err := r.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := st.SinceLastGrowth, time.Duration(0); g != w {
+		t.Errorf("SinceLastGrowth got %v, want %v", g, w)
+	}
+	// the growth is recorded only by the follow.Reader, which detects the truncation by it
+	if g, w := r.fu.observedSize, observed; g != w {
+		t.Errorf("observedSize got %v, want %v", g, w)
+	}
+}
+
+func wantStats(t *testing.T, r *Reader, want Stats) {
+	t.Helper()
+
+	got, err := r.Stats()
+	if err != nil {
+		t.Fatalf("failed to get stats: %+v", err)
+	}
+	if got.LastReadTime.IsZero() || got.SinceLastGrowth < 0 {
+		t.Errorf("stats got %+v", got)
+	}
+	got.LastReadTime, got.SinceLastGrowth = time.Time{}, 0
+	if got != want {
+		t.Errorf("stats got %+v, want %+v", got, want)
+	}
+}
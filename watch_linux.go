@@ -0,0 +1,124 @@
+//go:build linux
+
+package follow
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	inotifyFileMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_MOVE_SELF | syscall.IN_DELETE_SELF
+	inotifyDirMask  = syscall.IN_CREATE | syscall.IN_MOVED_TO
+	// inotifyModifyMask is the events that may indicate the growth. the overflow may have dropped any event
+	inotifyModifyMask = syscall.IN_MODIFY | syscall.IN_Q_OVERFLOW
+	// inotifyRotateMask is the events that may indicate the rotation
+	inotifyRotateMask = syscall.IN_ATTRIB | syscall.IN_MOVE_SELF | syscall.IN_DELETE_SELF | inotifyDirMask | syscall.IN_Q_OVERFLOW
+)
+
+// watchNotify watches the file currently read and the directory of the followFilePath by inotify.
+// The rotation is also checked every watchRotateInterval, since inotify cannot report the rotation
+// before the watches are added, the rotation out of the watched directory, and the events dropped by the overflow.
+func watchNotify(done, notify, wake chan struct{}, fu *fileUnit, followFilePath string, opt optionFollowRotate) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// the non-blocking fd is registered to the runtime poller, so Close interrupts Read
+	in := os.NewFile(uintptr(fd), "inotify")
+	if _, err := syscall.InotifyAddWatch(fd, fu.fileName(), inotifyFileMask); err != nil {
+		in.Close()
+		return os.NewSyscallError("inotify_add_watch", err)
+	}
+	if opt.followRotate {
+		if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(followFilePath), inotifyDirMask); err != nil {
+			in.Close()
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+	}
+	d := newRotateDetector(fu, followFilePath)
+
+	exit := make(chan struct{})
+	masks := make(chan uint32)
+	go func() {
+		defer close(masks)
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				select {
+				case <-done:
+				case <-exit:
+				default:
+					fu.log.Error("follow: failed to read inotify events. continue to check the rotation by polling", pathAttr(followFilePath), errAttr(err))
+				}
+				return
+			}
+			select {
+			case masks <- inotifyMask(buf[:n]):
+			case <-done:
+				return
+			case <-exit:
+				return
+			}
+		}
+	}()
+	go func() {
+		defer func() {
+			close(exit)
+			in.Close()
+		}()
+		tick := time.NewTicker(opt.watchRotateInterval)
+		defer tick.Stop()
+		// check the rotation that occurred before the watches are added
+		mask := uint32(syscall.IN_Q_OVERFLOW)
+		for {
+			if mask&inotifyModifyMask != 0 {
+				fu.observeSize()
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+			if opt.followRotate && mask&inotifyRotateMask != 0 && d.detect() {
+				notifyRotated(done, notify, fu, followFilePath, opt)
+				return
+			}
+			select {
+			case <-done:
+				return
+			case m, ok := <-masks:
+				if !ok {
+					// inotify failed. only the polling is left
+					masks = nil
+					mask = 0
+					continue
+				}
+				mask = m
+			case <-tick.C:
+				// check as if the events were dropped
+				mask = syscall.IN_Q_OVERFLOW
+			}
+		}
+	}()
+	return nil
+}
+
+// inotifyMask returns the union of the masks of the inotify events in b
+func inotifyMask(b []byte) uint32 {
+	var mask uint32
+	for len(b) >= syscall.SizeofInotifyEvent {
+		// struct inotify_event { int wd; uint32_t mask; uint32_t cookie; uint32_t len; char name[]; }
+		mask |= binary.NativeEndian.Uint32(b[4:8])
+		nameLen := binary.NativeEndian.Uint32(b[12:16])
+		next := syscall.SizeofInotifyEvent + int(nameLen)
+		if next > len(b) {
+			break
+		}
+		b = b[next:]
+	}
+	return mask
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package follow
+
+import "errors"
+
+func watchNotify(done, notify, wake chan struct{}, fu *fileUnit, followFilePath string, opt optionFollowRotate) error {
+	return errors.New("follow: the notification is not supported on this platform")
+}